@@ -7,3 +7,38 @@ const (
 	Dim2 Dim = 2
 	Dim3 Dim = 3
 )
+
+// Plane is the plane a 2D index lies on.
+type Plane int8
+
+const (
+	PlaneXZ Plane = iota // x and z, y is the altitude and ignored, the default.
+	PlaneXY              // x and y, z is ignored, like top-down or tile games.
+)
+
+// Axes returns the indexes of the two axes of the plane.
+func (p Plane) Axes() []int {
+	if p == PlaneXY {
+		return []int{0, 1}
+	}
+	return []int{0, 2}
+}
+
+// OutOfBounds is the policy of an octree or a quadtree for the entities out of its bound.
+type OutOfBounds int8
+
+const (
+	OutOfBoundsReject   OutOfBounds = iota // the entity is rejected, the default.
+	OutOfBoundsExpand                      // the tree grows a new root doubling its bound toward the entity until it fits.
+	OutOfBoundsClamp                       // the entity is held by the node at the nearest edge of the tree, keeping its location.
+	OutOfBoundsOverflow                    // the entity is held by the root as an overflow bucket, consulted by every query.
+)
+
+// Duplicate is the policy of a search tree for adding an entity whose ID is in it.
+type Duplicate int8
+
+const (
+	DuplicateReject  Duplicate = iota // the entity is rejected, the default.
+	DuplicateReplace                  // the entity held is removed, then the entity is added as a new one.
+	DuplicateUpdate                   // the entity is moved from the location of the entity held, like Update.
+)
@@ -1,12 +1,8 @@
 module github.com/cozmo-zh/zearches
 
-go 1.22.5
+go 1.23
 
-
-require (
-	github.com/dhconnelly/rtreego v1.2.0
-	github.com/stretchr/testify v1.9.0
-)
+require github.com/stretchr/testify v1.9.0
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
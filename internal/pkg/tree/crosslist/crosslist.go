@@ -0,0 +1,683 @@
+// Package crosslist provides an implementation of the cross-linked list AOI,
+// entities are kept in doubly linked lists sorted along each axis.
+package crosslist
+
+import (
+	"fmt"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/filter"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// maxLevel is the maximum number of the skip levels of the x list.
+const maxLevel = 16
+
+// node is an entity linked in the axis lists.
+type node struct {
+	entity siface.ISpatial
+	key    [3]int32 // The location the node is sorted by on each axis, the entity may have moved in place since.
+	prev   [3]*node // Previous node on each axis.
+	next   [3]*node // Next node on each axis.
+	skip   []*node  // Next node on each skip level of the x list it rises to, the x list itself being level 0.
+}
+
+// CrossList represents a cross-linked list, the entities are sorted along x and z (and y if it's 3D).
+//
+// Moving an entity only walks the nodes between its old and new positions,
+// which is cheap when the entities move a little at a time.
+// The x list is a skip list, the queries and the entities added find their place on it in logarithmic time.
+type CrossList struct {
+	dim    consts.Dim
+	axes   []int           // The axes with a list.
+	heads  [3]*node        // The first node of each axis list.
+	skips  []*node         // The first node of each skip level of the x list, where the queries start from the top.
+	nodes  map[int64]*node // Map of entity IDs to their nodes.
+	extent tree.Extent     // How far the bounds of the entities reach beyond their locations.
+	option *option.OptionalSettings
+}
+
+// NewCrossList creates a new CrossList.
+// Parameters:
+// - dim: the dimension of the list, a 2D list ignores y.
+// - optional: variadic optional parameters to configure the list, mergeIf doesn't apply.
+func NewCrossList(dim consts.Dim, optional ...option.Optional) (*CrossList, error) {
+	c := &CrossList{
+		dim:    dim,
+		nodes:  make(map[int64]*node),
+		option: option.OptionalDefault(),
+	}
+	switch dim {
+	case consts.Dim2:
+		c.axes = []int{0, 2}
+	case consts.Dim3:
+		c.axes = []int{0, 1, 2}
+	default:
+		return nil, fmt.Errorf("unsupported dimension: %v", dim)
+	}
+	for _, opt := range optional {
+		opt(c.option)
+	}
+	return c, nil
+}
+
+// Add adds an entity to the list.
+// Returns true if the entity was added successfully, false if an entity with the same ID exists, see AddE.
+func (c *CrossList) Add(entity siface.ISpatial) bool {
+	return c.AddE(entity) == nil
+}
+
+// AddE adds an entity to the list, returns siface.ErrInvalidBound or siface.ErrDuplicateID if it's rejected, see option.WithDuplicate.
+func (c *CrossList) AddE(entity siface.ISpatial) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	if _, ok := c.nodes[entity.GetID()]; ok {
+		return tree.AddDuplicate(c, entity, c.option.Duplicate())
+	}
+	n := c.newNode(entity)
+	c.linkX(n)
+	for _, axis := range c.axes[1:] {
+		var p *node
+		for q := c.heads[axis]; q != nil && before(q, n, axis); q = q.next[axis] {
+			p = q
+		}
+		c.insertAfter(n, p, axis)
+	}
+	c.nodes[entity.GetID()] = n
+	c.extent.Grow(entity)
+	return nil
+}
+
+// AddBatch adds the entities to the list in one pass, each axis list is merged with the sorted entities
+// instead of walking it from the head for each entity like Add.
+// Returns the number of entities added, the ones whose ID is in the tree or repeated in the batch are added one by one
+// by the duplicate policy, see option.WithDuplicate, and the ones rejected by AddE are skipped.
+func (c *CrossList) AddBatch(entities []siface.ISpatial) int {
+	entities, duplicates := tree.Admissible(entities, c.Contains)
+	nodes := make([]*node, 0, len(entities))
+	for _, entity := range entities {
+		n := c.newNode(entity)
+		c.nodes[entity.GetID()] = n
+		c.extent.Grow(entity)
+		nodes = append(nodes, n)
+	}
+	for _, axis := range c.axes {
+		sort.Slice(nodes, func(i, j int) bool { return before(nodes[i], nodes[j], axis) })
+		var p *node
+		q := c.heads[axis]
+		for _, n := range nodes {
+			for q != nil && before(q, n, axis) {
+				p, q = q, q.next[axis]
+			}
+			c.insertAfter(n, p, axis)
+			p = n
+		}
+	}
+	c.relinkSkips()
+	return len(nodes) + tree.AddEach(c, duplicates)
+}
+
+// Remove removes an entity from the list by its ID.
+// Returns true if the entity was removed successfully, false otherwise.
+func (c *CrossList) Remove(entityId int64) bool {
+	return c.RemoveE(entityId) == nil
+}
+
+// RemoveE removes an entity from the list by its ID, returns siface.ErrNotFound if it's not in the list.
+func (c *CrossList) RemoveE(entityId int64) error {
+	n, ok := c.nodes[entityId]
+	if !ok {
+		return tree.NotFound(entityId)
+	}
+	c.unlinkX(n)
+	for _, axis := range c.axes[1:] {
+		c.unlink(n, axis)
+	}
+	delete(c.nodes, entityId)
+	return nil
+}
+
+// Get returns the entity with the given ID.
+func (c *CrossList) Get(entityId int64) (siface.ISpatial, bool) {
+	if n, ok := c.nodes[entityId]; ok {
+		return n.entity, true
+	}
+	return nil, false
+}
+
+// Contains checks if an entity with the given ID is in the list.
+func (c *CrossList) Contains(entityId int64) bool {
+	_, ok := c.nodes[entityId]
+	return ok
+}
+
+// Update moves an entity to the position of its new location in each axis list,
+// only walking the neighbours between its old and new positions, or down the skip levels on the x list.
+// oldLocation is not needed, the node of the entity is looked up by ID and found by the location it was sorted by.
+func (c *CrossList) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	return c.UpdateE(entity, oldLocation) == nil
+}
+
+// UpdateE moves an entity like Update, returns siface.ErrInvalidBound or siface.ErrNotFound if it fails.
+func (c *CrossList) UpdateE(entity siface.ISpatial, _ geo.Vec3Int) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	n, ok := c.nodes[entity.GetID()]
+	if !ok {
+		return tree.NotFound(entity.GetID())
+	}
+	n.entity = entity
+	for _, axis := range c.axes {
+		c.reposition(n, axis)
+	}
+	c.extent.Grow(entity)
+	return nil
+}
+
+// UpdateWithDiff moves an entity like Update, and reports the entities entering and leaving
+// the surroundings of the entity within radius. Only the entities within radius on x of the span the entity
+// swept may enter or leave, they are walked once around its new position on the x list.
+// Parameters:
+// - entity: the spatial entity at its new location.
+// - oldLocation: the location the entity was at.
+// - radius: the radius of the surroundings.
+// Returns the entities entered, the entities left, and false if the entity is unknown or its bound is invalid.
+func (c *CrossList) UpdateWithDiff(entity siface.ISpatial, oldLocation geo.Vec3Int, radius float32) ([]siface.ISpatial, []siface.ISpatial, bool) {
+	n, ok := c.nodes[entity.GetID()]
+	if !ok || tree.CheckBound(entity) != nil {
+		return nil, nil, false
+	}
+	c.Update(entity, oldLocation)
+	location := entity.GetLocation()
+	r := int64(math.Ceil(float64(radius)))
+	from := int64(min(oldLocation.X(), location.X())) - r
+	to := int64(max(oldLocation.X(), location.X())) + r
+	enter := make([]siface.ISpatial, 0)
+	leave := make([]siface.ISpatial, 0)
+	diff := func(q *node) {
+		was := c.distanceSquared(q.entity.GetLocation(), oldLocation) <= float64(radius)*float64(radius)
+		is := c.distanceSquared(q.entity.GetLocation(), location) <= float64(radius)*float64(radius)
+		if is && !was {
+			enter = append(enter, q.entity)
+		} else if was && !is {
+			leave = append(leave, q.entity)
+		}
+	}
+	for q := n.prev[0]; q != nil && int64(key(q, 0)) >= from; q = q.prev[0] {
+		diff(q)
+	}
+	for q := n.next[0]; q != nil && int64(key(q, 0)) <= to; q = q.next[0] {
+		diff(q)
+	}
+	return enter, leave, true
+}
+
+// GetSurroundingEntities finds entities within a certain radius of a center point.
+// Parameters:
+// - center: the center point to search around.
+// - radius: the radius within which to search for entities.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities within the specified radius.
+func (c *CrossList) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return c.AppendSurroundingEntities(make([]siface.ISpatial, 0), center, radius, filters...)
+}
+
+// AppendSurroundingEntities appends the entities within a certain radius of a center point to dst,
+// and returns the extended slice, like GetSurroundingEntities without allocating a new one.
+func (c *CrossList) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.visitSurroundingEntities(center, radius, c.option.Metric(), func(entity siface.ISpatial) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters)
+	return dst
+}
+
+// VisitSurroundingEntities calls visit for each entity within a certain radius of a center point,
+// until visit returns false.
+func (c *CrossList) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	c.visitSurroundingEntities(center, radius, c.option.Metric(), visit, filters)
+}
+
+// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric,
+// a 2D list ignores y. The euclidean distance is used if the metric is nil.
+func (c *CrossList) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	c.visitSurroundingEntities(center, radius, metric, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	}, filters)
+	return ret
+}
+
+// visitSurroundingEntities calls visit for each entity within radius of the center measured by the metric, until visit returns false.
+func (c *CrossList) visitSurroundingEntities(center []float32, radius float32, metric util.DistanceMetric, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) {
+	var buf [3]int32
+	ct := c.option.ScaleTo(buf[:], center)
+	if metric == nil {
+		r := int32(math.Ceil(float64(radius)))
+		within := func(entity siface.ISpatial) bool {
+			return c.distanceSquared(entity.GetLocation(), ct) <= float64(radius)*float64(radius)
+		}
+		c.visit(ct.X()-r, ct.X()+r, within, filters, visit)
+		return
+	}
+	mq := tree.NewMetricQuery(metric, ct, radius, c.axes)
+	within := func(entity siface.ISpatial) bool {
+		return mq.Within(entity.GetLocation())
+	}
+	reach := mq.Bound()
+	c.visit(reach.Min.X(), reach.Max.X(), within, filters, visit)
+}
+
+// GetNearest finds the k entities nearest to a center point, sorted by ascending distance.
+// maxDistance limits the distance of the entities, there is no limit if it's not positive.
+//
+// The x list is walked outward from the center in both directions,
+// until the distance on x alone exceeds the k-th nearest distance.
+func (c *CrossList) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	type candidate struct {
+		entity siface.ISpatial
+		dist   float64
+	}
+	ret := make([]siface.ISpatial, 0)
+	if k <= 0 {
+		return ret
+	}
+	ct := c.option.ScaleFunc(center)
+	cx := float64(ct.X())
+	left := c.floor(func(q *node) bool { return float64(key(q, 0)) < cx })
+	right := c.forward(left, 0)
+	candidates := make([]candidate, 0)
+	for left != nil || right != nil {
+		var n *node
+		if right == nil || (left != nil && cx-float64(key(left, 0)) < float64(key(right, 0))-cx) {
+			n, left = left, left.prev[0]
+		} else {
+			n, right = right, right.next[0]
+		}
+		dx := math.Abs(float64(key(n, 0)) - cx)
+		if maxDistance > 0 && dx > float64(maxDistance) {
+			break
+		}
+		if len(candidates) >= k && dx*dx > candidates[k-1].dist {
+			break
+		}
+		dist := c.distanceSquared(n.entity.GetLocation(), ct)
+		if (maxDistance > 0 && dist > float64(maxDistance)*float64(maxDistance)) || !filter.Match(n.entity, filters...) {
+			continue
+		}
+		i := sort.Search(len(candidates), func(i int) bool { return candidates[i].dist > dist })
+		candidates = append(candidates, candidate{})
+		copy(candidates[i+1:], candidates[i:])
+		candidates[i] = candidate{entity: n.entity, dist: dist}
+		if len(candidates) > k {
+			candidates = candidates[:k]
+		}
+	}
+	for _, cd := range candidates {
+		ret = append(ret, cd.entity)
+	}
+	return ret
+}
+
+// GetEntitiesInBound finds entities whose location is within a box, a 2D list ignores y.
+func (c *CrossList) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	inBound := func(entity siface.ISpatial) bool {
+		if c.dim == consts.Dim2 {
+			return bound.Contains2D(entity.GetLocation())
+		}
+		return bound.Contains(entity.GetLocation())
+	}
+	return c.scan(bound.Min.X(), bound.Max.X(), inBound, filters)
+}
+
+// GetIntersecting finds entities whose bound intersects a box, a 2D list ignores y.
+func (c *CrossList) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	intersects := func(entity siface.ISpatial) bool {
+		if c.dim == consts.Dim2 {
+			return tree.BoundOf(entity).Intersects2D(bound)
+		}
+		return tree.BoundOf(entity).Intersects(bound)
+	}
+	expanded := c.extent.Expand(bound)
+	return c.scan(expanded.Min.X(), expanded.Max.X(), intersects, filters)
+}
+
+// GetCollisionPairs finds all pairs of entities whose bounds overlap.
+func (c *CrossList) GetCollisionPairs() [][2]siface.ISpatial {
+	return tree.CollisionPairs(c.rangeEntities, func(bound bounds.Bound) []siface.ISpatial {
+		return c.GetIntersecting(bound)
+	})
+}
+
+// All returns an iterator over all the entities in the list.
+func (c *CrossList) All() iter.Seq[siface.ISpatial] {
+	return c.rangeEntities
+}
+
+// Within returns an iterator over the entities within a certain radius of a center point,
+// the search stops when the loop breaks.
+func (c *CrossList) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		c.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+// Len returns the number of entities in the list.
+func (c *CrossList) Len() int {
+	return len(c.nodes)
+}
+
+// Stats returns the statistics of the list, only Entities and MemoryBytes are collected as it has no tree of nodes,
+// the other fields are always zero.
+func (c *CrossList) Stats() siface.Stats {
+	stats := siface.Stats{
+		Entities:    len(c.nodes),
+		MemoryBytes: int(unsafe.Sizeof(*c)) + tree.MapSize + len(c.nodes)*(int(unsafe.Sizeof(node{}))+tree.MapEntrySize) + cap(c.skips)*int(unsafe.Sizeof(c)),
+	}
+	for _, n := range c.nodes {
+		stats.MemoryBytes += cap(n.skip) * int(unsafe.Sizeof(n))
+	}
+	return stats
+}
+
+// Snapshot writes the dimension and the entities of the list to w, see Restore.
+func (c *CrossList) Snapshot(w io.Writer) error {
+	e := tree.NewEncoder(w, tree.KindCrossList)
+	e.Uint8(uint8(c.dim))
+	tree.EncodeEntities(e, len(c.nodes), c.rangeEntities)
+	return e.Close()
+}
+
+// Restore restores a list from a snapshot written by Snapshot, the entities are linked at their current locations.
+// Parameters:
+// - d: the decoder of the snapshot, whose header has been read.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil.
+// - optional: variadic optional parameters to configure the list.
+func Restore(d *tree.Decoder, resolve func(id int64) siface.ISpatial, optional ...option.Optional) (*CrossList, error) {
+	dim := consts.Dim(d.Uint8())
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	c, err := NewCrossList(dim, optional...)
+	if err != nil {
+		return nil, err
+	}
+	entities := tree.DecodeEntities(d, resolve)
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	c.AddBatch(entities)
+	return c, nil
+}
+
+// ToDot generates a dot file for the list, each axis list is drawn as a chain of entities.
+func (c *CrossList) ToDot() error {
+	const fileName = "crosslist.dot"
+	if c.option.DrawPath() == "" {
+		return fmt.Errorf("draw path not set")
+	}
+	if file, err := os.OpenFile(path.Join(c.option.DrawPath(), fileName), os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	} else {
+		defer file.Close()
+		return tree.WriteDot(tree.GetTemplate(), c.toPNode(), file)
+	}
+}
+
+// toPNode converts the list to the nodes of the dot template.
+func (c *CrossList) toPNode() *tree.PNode {
+	names := [3]string{"axis_x", "axis_y", "axis_z"}
+	p := &tree.PNode{
+		Nodes:    make([]*tree.Elem, 0),
+		Entities: make([]*tree.Elem, 0),
+		Edges:    make([]*tree.Pair, 0),
+	}
+	elems := make(map[int64]*tree.Elem, len(c.nodes))
+	for n := c.heads[0]; n != nil; n = n.next[0] {
+		el := &tree.Elem{
+			Name:  fmt.Sprintf("entity_%d", n.entity.GetID()),
+			Label: strconv.Itoa(int(n.entity.GetID())),
+		}
+		elems[n.entity.GetID()] = el
+		p.Entities = append(p.Entities, el)
+	}
+	for _, axis := range c.axes {
+		parent := &tree.Elem{Name: names[axis], Label: names[axis]}
+		p.Nodes = append(p.Nodes, parent)
+		for n := c.heads[axis]; n != nil; n = n.next[axis] {
+			child := elems[n.entity.GetID()]
+			p.Edges = append(p.Edges, &tree.Pair{Parent: parent, Child: child})
+			parent = child
+		}
+	}
+	return p
+}
+
+// scan collects the entities accepted whose x is within [from, to].
+func (c *CrossList) scan(from, to int32, accept func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	c.visit(from, to, accept, filters, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	})
+	return ret
+}
+
+// visit calls f for each entity accepted whose x is within [from, to], until f returns false.
+func (c *CrossList) visit(from, to int32, accept func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool, f func(entity siface.ISpatial) bool) {
+	for n := c.forward(c.floor(func(q *node) bool { return key(q, 0) < from }), 0); n != nil && key(n, 0) <= to; n = n.next[0] {
+		if accept(n.entity) && filter.Match(n.entity, filters...) && !f(n.entity) {
+			return
+		}
+	}
+}
+
+// newNode returns the node of the entity, sorted by its location, rising to a random number of skip levels.
+func (c *CrossList) newNode(entity siface.ISpatial) *node {
+	n := &node{entity: entity}
+	copy(n.key[:], entity.GetLocation())
+	level := 0
+	// each skip level holds a quarter of the nodes of the level below.
+	for level < maxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	if level > 0 {
+		n.skip = make([]*node, level)
+	}
+	for len(c.skips) < level {
+		c.skips = append(c.skips, nil)
+	}
+	return n
+}
+
+// forward returns the next node of p on the level of the x list, the first node of the level if p is nil.
+func (c *CrossList) forward(p *node, level int) *node {
+	switch {
+	case p == nil && level == 0:
+		return c.heads[0]
+	case p == nil:
+		return c.skips[level-1]
+	case level == 0:
+		return p.next[0]
+	default:
+		return p.skip[level-1]
+	}
+}
+
+// setForward sets the next node of p on a skip level of the x list, the first node of the level if p is nil.
+func (c *CrossList) setForward(p *node, level int, n *node) {
+	if p == nil {
+		c.skips[level-1] = n
+	} else {
+		p.skip[level-1] = n
+	}
+}
+
+// floor returns the last node of the x list accepted by less, nil if there is none,
+// less accepts the nodes before a position of the list only.
+func (c *CrossList) floor(less func(q *node) bool) *node {
+	var p *node
+	for level := len(c.skips); level >= 0; level-- {
+		for q := c.forward(p, level); q != nil && less(q); q = c.forward(p, level) {
+			p = q
+		}
+	}
+	return p
+}
+
+// linkX links the node into the x list and the skip levels it rises to.
+func (c *CrossList) linkX(n *node) {
+	var p *node
+	for level := len(c.skips); level >= 0; level-- {
+		for q := c.forward(p, level); q != nil && before(q, n, 0); q = c.forward(p, level) {
+			p = q
+		}
+		if level == 0 {
+			c.insertAfter(n, p, 0)
+		} else if level <= len(n.skip) {
+			n.skip[level-1] = c.forward(p, level)
+			c.setForward(p, level, n)
+		}
+	}
+}
+
+// unlinkX removes the node from the x list and the skip levels it rises to, it's found by the key it's sorted by.
+func (c *CrossList) unlinkX(n *node) {
+	var p *node
+	for level := len(c.skips); level > 0; level-- {
+		for q := c.forward(p, level); q != nil && before(q, n, 0); q = c.forward(p, level) {
+			p = q
+		}
+		if level <= len(n.skip) {
+			c.setForward(p, level, n.skip[level-1])
+			n.skip[level-1] = nil
+		}
+	}
+	c.unlink(n, 0)
+}
+
+// relinkSkips links the skip levels of the x list again in one pass over it.
+func (c *CrossList) relinkSkips() {
+	clear(c.skips)
+	tails := make([]*node, len(c.skips))
+	for n := c.heads[0]; n != nil; n = n.next[0] {
+		for level := 1; level <= len(n.skip); level++ {
+			n.skip[level-1] = nil
+			c.setForward(tails[level-1], level, n)
+			tails[level-1] = n
+		}
+	}
+}
+
+// reposition moves the node to keep the axis list sorted after its location changed.
+// The x list is relinked through its skip levels, the other lists are walked from the old position.
+func (c *CrossList) reposition(n *node, axis int) {
+	old, v := n.key[axis], n.entity.GetLocation()[axis]
+	if old == v {
+		return
+	}
+	n.key[axis] = v
+	prev, next := n.prev[axis], n.next[axis]
+	if (prev == nil || before(prev, n, axis)) && (next == nil || before(n, next, axis)) {
+		// still in order.
+		return
+	}
+	if axis == 0 {
+		n.key[0] = old
+		c.unlinkX(n)
+		n.key[0] = v
+		c.linkX(n)
+		return
+	}
+	c.unlink(n, axis)
+	p := prev
+	if prev != nil && before(n, prev, axis) {
+		// walk backward.
+		for p != nil && before(n, p, axis) {
+			p = p.prev[axis]
+		}
+	} else {
+		// walk forward.
+		p = next
+		for p.next[axis] != nil && before(p.next[axis], n, axis) {
+			p = p.next[axis]
+		}
+	}
+	c.insertAfter(n, p, axis)
+}
+
+// insertAfter links the node after p in the axis list, at the head if p is nil.
+func (c *CrossList) insertAfter(n, p *node, axis int) {
+	n.prev[axis] = p
+	if p == nil {
+		n.next[axis] = c.heads[axis]
+		c.heads[axis] = n
+	} else {
+		n.next[axis] = p.next[axis]
+		p.next[axis] = n
+	}
+	if n.next[axis] != nil {
+		n.next[axis].prev[axis] = n
+	}
+}
+
+// unlink removes the node from the axis list.
+func (c *CrossList) unlink(n *node, axis int) {
+	if n.prev[axis] == nil {
+		c.heads[axis] = n.next[axis]
+	} else {
+		n.prev[axis].next[axis] = n.next[axis]
+	}
+	if n.next[axis] != nil {
+		n.next[axis].prev[axis] = n.prev[axis]
+	}
+	n.prev[axis], n.next[axis] = nil, nil
+}
+
+// rangeEntities ranges all the entities in the list.
+func (c *CrossList) rangeEntities(f func(entity siface.ISpatial) bool) {
+	for n := c.heads[0]; n != nil; n = n.next[0] {
+		if !f(n.entity) {
+			return
+		}
+	}
+}
+
+// distanceSquared returns the squared distance between two locations, a 2D list ignores y.
+func (c *CrossList) distanceSquared(p1, p2 geo.Vec3Int) float64 {
+	sum := 0.0
+	for _, axis := range c.axes {
+		d := float64(p1[axis]) - float64(p2[axis])
+		sum += d * d
+	}
+	return sum
+}
+
+// key returns the sort key of the node on the axis.
+func key(n *node, axis int) int32 {
+	return n.key[axis]
+}
+
+// before checks if the node a is sorted before b on the axis, the nodes at the same place are sorted by their IDs.
+func before(a, b *node, axis int) bool {
+	if a.key[axis] != b.key[axis] {
+		return a.key[axis] < b.key[axis]
+	}
+	return a.entity.GetID() < b.entity.GetID()
+}
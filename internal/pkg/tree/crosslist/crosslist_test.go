@@ -0,0 +1,242 @@
+// Package crosslist .
+package crosslist
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"testing"
+)
+
+// assertSorted checks that each axis list is sorted and linked both ways, and that each skip level skips along the x list.
+func assertSorted(t *testing.T, c *CrossList) {
+	for _, axis := range c.axes {
+		count := 0
+		var prev *node
+		for n := c.heads[axis]; n != nil; n = n.next[axis] {
+			assert.Equal(t, prev, n.prev[axis])
+			if prev != nil {
+				assert.True(t, before(prev, n, axis))
+			}
+			prev = n
+			count++
+		}
+		assert.Equal(t, len(c.nodes), count)
+	}
+	for level := 1; level <= len(c.skips); level++ {
+		// the nodes rising to the level, in the order of the x list.
+		expected := make([]*node, 0)
+		for n := c.heads[0]; n != nil; n = n.next[0] {
+			if len(n.skip) >= level {
+				expected = append(expected, n)
+			}
+		}
+		got := make([]*node, 0)
+		for n := c.skips[level-1]; n != nil; n = n.skip[level-1] {
+			got = append(got, n)
+		}
+		assert.Equal(t, expected, got, level)
+	}
+}
+
+func TestCrossList_creation(t *testing.T) {
+	c, err := NewCrossList(consts.Dim2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 2}, c.axes)
+	c, err = NewCrossList(consts.Dim3)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2}, c.axes)
+	_, err = NewCrossList(consts.Dim(4))
+	assert.NotNil(t, err)
+}
+
+func TestCrossList_AddRemoveUpdate(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim3)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		assert.True(t, c.Add(mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101))))
+	}
+	assert.False(t, c.Add(mocks.CreateMockSpatial(1, 0, 0, 0)))
+	assertSorted(t, c)
+
+	for i := 0; i < 100; i++ {
+		old, _ := c.Get(int64(i))
+		moved := mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101))
+		assert.True(t, c.Update(moved, old.GetLocation()))
+		got, ok := c.Get(int64(i))
+		assert.True(t, ok)
+		assert.Equal(t, moved, got)
+	}
+	assertSorted(t, c)
+
+	for i := 0; i < 100; i += 2 {
+		assert.True(t, c.Remove(int64(i)))
+		assert.False(t, c.Contains(int64(i)))
+	}
+	assert.False(t, c.Remove(0))
+	assert.False(t, c.Update(mocks.CreateMockSpatial(0, 0, 0, 0), geo.NewVec3Int(0, 0, 0)))
+	assertSorted(t, c)
+}
+
+func TestCrossList_MovedInPlace(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim2)
+	entities := make([]siface.ISpatial, 0)
+	for i := 0; i < 10; i++ {
+		entity := mocks.CreateMockSpatial(int64(i), int32(i*10), 0, 0)
+		entities = append(entities, entity)
+		c.Add(entity)
+	}
+	// the entities are moved before the list is told.
+	moved := entities[2].(*mocks.MockSpatial)
+	old := moved.GetLocation()
+	moved.Location = geo.NewVec3Int(95, 0, 0)
+	assert.True(t, c.Update(moved, old))
+	assertSorted(t, c)
+	assert.Equal(t, []siface.ISpatial{moved}, c.GetSurroundingEntities([]float32{95, 0, 0}, 1))
+	assert.Empty(t, c.GetSurroundingEntities([]float32{20, 0, 0}, 1))
+
+	removed := entities[5].(*mocks.MockSpatial)
+	removed.Location = geo.NewVec3Int(-10, 0, 0)
+	assert.True(t, c.Remove(5))
+	assertSorted(t, c)
+	assert.Equal(t, 9, c.Len())
+	assert.Empty(t, c.GetSurroundingEntities([]float32{50, 0, 0}, 1))
+}
+
+func TestCrossList_AddBatch(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim3)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		c.Add(mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101)))
+	}
+	batch := make([]siface.ISpatial, 0)
+	for i := 50; i < 100; i++ {
+		batch = append(batch, mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101)))
+	}
+	assert.Equal(t, 50, c.AddBatch(batch))
+	assertSorted(t, c)
+}
+
+func TestCrossList_QueryWindow(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim2)
+	for i := 0; i < 1000; i++ {
+		c.Add(mocks.CreateMockSpatial(int64(i), int32(i), 0, 0))
+	}
+	// only the nodes whose x is within the window are visited.
+	visited := 0
+	c.visit(500, 509, func(entity siface.ISpatial) bool {
+		visited++
+		return true
+	}, nil, func(entity siface.ISpatial) bool { return true })
+	assert.Equal(t, 10, visited)
+	assert.Equal(t, 3, len(c.GetSurroundingEntities([]float32{700, 0, 0}, 1)))
+	assert.Equal(t, int64(999), c.GetNearest([]float32{2000, 0, 0}, 1, 0)[0].GetID())
+}
+
+func TestCrossList_QueriesMatchBruteForce(t *testing.T) {
+	for _, dim := range []consts.Dim{consts.Dim2, consts.Dim3} {
+		c, _ := NewCrossList(dim)
+		dist := util.Distance3D
+		if dim == consts.Dim2 {
+			dist = util.Distance2D
+		}
+		r := rand.New(rand.NewSource(1))
+		entities := make([]siface.ISpatial, 0)
+		for i := 0; i < 300; i++ {
+			entity := mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101))
+			entities = append(entities, entity)
+			c.Add(entity)
+		}
+		center := []float32{30, 40, 50}
+		expected := make([]siface.ISpatial, 0)
+		for _, entity := range entities {
+			if dist(entity.GetLocation().ToFloat32(), center) <= 20 {
+				expected = append(expected, entity)
+			}
+		}
+		assert.ElementsMatch(t, expected, c.GetSurroundingEntities(center, 20))
+
+		sort.SliceStable(entities, func(i, j int) bool {
+			return dist(entities[i].GetLocation().ToFloat32(), center) < dist(entities[j].GetLocation().ToFloat32(), center)
+		})
+		nearest := c.GetNearest(center, 10, 0)
+		assert.Len(t, nearest, 10)
+		for i, entity := range nearest {
+			assert.Equal(t, dist(entities[i].GetLocation().ToFloat32(), center), dist(entity.GetLocation().ToFloat32(), center))
+		}
+		for _, entity := range c.GetNearest(center, 300, 15) {
+			assert.LessOrEqual(t, dist(entity.GetLocation().ToFloat32(), center), float32(15))
+		}
+
+		bound := bounds.NewBound(geo.NewVec3Int(10, 10, 10), geo.NewVec3Int(40, 40, 40))
+		expected = expected[:0]
+		for _, entity := range entities {
+			if (dim == consts.Dim2 && bound.Contains2D(entity.GetLocation())) || (dim == consts.Dim3 && bound.Contains(entity.GetLocation())) {
+				expected = append(expected, entity)
+			}
+		}
+		assert.ElementsMatch(t, expected, c.GetEntitiesInBound(bound))
+	}
+}
+
+func TestCrossList_UpdateWithDiff(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim2)
+	watcher := mocks.CreateMockSpatial(1, 0, 0, 0)
+	near := mocks.CreateMockSpatial(2, 5, 0, 0)
+	far := mocks.CreateMockSpatial(3, 30, 0, 0)
+	c.Add(watcher)
+	c.Add(near)
+	c.Add(far)
+
+	moved := mocks.CreateMockSpatial(1, 25, 0, 0)
+	enter, leave, ok := c.UpdateWithDiff(moved, watcher.GetLocation(), 10)
+	assert.True(t, ok)
+	assert.Equal(t, []siface.ISpatial{far}, enter)
+	assert.Equal(t, []siface.ISpatial{near}, leave)
+	assert.Equal(t, []siface.ISpatial{moved}, c.GetSurroundingEntities([]float32{25, 0, 0}, 1))
+	assertSorted(t, c)
+
+	enter, leave, ok = c.UpdateWithDiff(mocks.CreateMockSpatial(1, 26, 0, 0), moved.GetLocation(), 10)
+	assert.True(t, ok)
+	assert.Empty(t, enter)
+	assert.Empty(t, leave)
+
+	_, _, ok = c.UpdateWithDiff(mocks.CreateMockSpatial(4, 0, 0, 0), geo.NewVec3Int(0, 0, 0), 10)
+	assert.False(t, ok)
+}
+
+func TestCrossList_GetIntersecting(t *testing.T) {
+	c, _ := NewCrossList(consts.Dim3)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10, bounds.NewBound(geo.NewVec3Int(5, 5, 5), geo.NewVec3Int(15, 15, 15)))
+	entity2 := mocks.CreateMockSpatial(2, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(15, 15, 15), geo.NewVec3Int(85, 85, 85)))
+	entity3 := mocks.CreateMockSpatial(3, 95, 95, 95)
+	c.Add(entity1)
+	c.Add(entity2)
+	c.Add(entity3)
+	ret := c.GetIntersecting(bounds.NewBound(geo.NewVec3Int(80, 80, 80), geo.NewVec3Int(100, 100, 100)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity2, entity3}, ret)
+	assert.Equal(t, [][2]siface.ISpatial{{entity1, entity2}}, c.GetCollisionPairs())
+}
+
+func TestCrossList_ToDot(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := NewCrossList(consts.Dim2, option.WithDrawPath(dir))
+	c.Add(mocks.CreateMockSpatial(1, 10, 0, 60))
+	c.Add(mocks.CreateMockSpatial(2, 60, 0, 10))
+	assert.Nil(t, c.ToDot())
+	content, err := os.ReadFile(path.Join(dir, "crosslist.dot"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "axis_x -> entity_1")
+	assert.Contains(t, string(content), "entity_1 -> entity_2")
+	assert.Contains(t, string(content), "axis_z -> entity_2")
+	assert.Contains(t, string(content), "entity_2 -> entity_1")
+}
@@ -36,9 +36,14 @@ func parseNode(e *Elem, n *treenode.TreeNode) {
 }
 
 func ToDot(path string, root *treenode.TreeNode, output io.Writer) error {
+	return WriteDot(path, ToPNod(root), output)
+}
+
+// WriteDot renders the nodes with the template at path to output.
+func WriteDot(path string, node *PNode, output io.Writer) error {
 	if tpl, err := template.ParseFiles(path); err != nil {
 		return err
-	} else if err = tpl.Execute(output, ToPNod(root)); err != nil {
+	} else if err = tpl.Execute(output, node); err != nil {
 		return err
 	} else {
 		return nil
@@ -62,23 +67,22 @@ func ToPNod(root *treenode.TreeNode) *PNode {
 			edges = append(edges, pair)
 		}
 		nodes = append(nodes, node)
-		if n.IsLeaf() {
-			n.RangeEntities(func(entity siface.ISpatial) bool {
-				el := &Elem{
-					Name:  fmt.Sprintf("entity_%d", entity.GetID()),
-					Label: strconv.Itoa(int(entity.GetID())),
-				}
-				entities = append(entities, el)
-				pair := &Pair{
-					Child: el,
-				}
-				parent := &Elem{}
-				parseNode(parent, n)
-				pair.Parent = parent
-				edges = append(edges, pair)
-				return true
-			})
-		}
+		// an internal node holds entities only if the tree is loose.
+		n.RangeEntities(func(entity siface.ISpatial) bool {
+			el := &Elem{
+				Name:  fmt.Sprintf("entity_%d", entity.GetID()),
+				Label: strconv.Itoa(int(entity.GetID())),
+			}
+			entities = append(entities, el)
+			pair := &Pair{
+				Child: el,
+			}
+			parent := &Elem{}
+			parseNode(parent, n)
+			pair.Parent = parent
+			edges = append(edges, pair)
+			return true
+		})
 		return true
 	})
 	return &PNode{
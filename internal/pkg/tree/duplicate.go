@@ -0,0 +1,49 @@
+// Package tree .
+package tree
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// AddDuplicate adds the entity whose ID is in the search tree by the policy, see consts.Duplicate.
+//
+// Parameters:
+// - search: the search tree holding an entity with the ID.
+// - entity: the spatial entity to be added.
+// - policy: the policy for adding an entity whose ID is in the search tree.
+//
+// Returns siface.ErrDuplicateID if it's rejected, or the error of adding it again,
+// the entity held stays then.
+func AddDuplicate(search siface.ISearch, entity siface.ISpatial, policy consts.Duplicate) error {
+	held, ok := search.Get(entity.GetID())
+	if !ok {
+		return search.AddE(entity)
+	}
+	switch policy {
+	case consts.DuplicateReplace:
+		if err := search.RemoveE(entity.GetID()); err != nil {
+			return err
+		}
+		if err := search.AddE(entity); err != nil {
+			_ = search.AddE(held)
+			return err
+		}
+		return nil
+	case consts.DuplicateUpdate:
+		return search.UpdateE(entity, held.GetLocation())
+	default:
+		return DuplicateID(entity.GetID())
+	}
+}
+
+// AddEach adds the entities to the search tree one by one, returns the number of the ones AddE accepts.
+func AddEach(search siface.ISearch, entities []siface.ISpatial) int {
+	added := 0
+	for _, entity := range entities {
+		if search.AddE(entity) == nil {
+			added++
+		}
+	}
+	return added
+}
@@ -0,0 +1,66 @@
+// Package tree .
+package tree
+
+import (
+	"fmt"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// CheckBound returns siface.ErrInvalidBound if the bound of the entity is set partially or its min exceeds its max on an axis,
+// an entity without bound is a point at its location.
+func CheckBound(entity siface.ISpatial) error {
+	bound := entity.GetBound()
+	if len(bound.Min) == 0 && len(bound.Max) == 0 {
+		return nil
+	}
+	if len(bound.Min) != 3 || len(bound.Max) != 3 {
+		return fmt.Errorf("%w: entity %d, %v", siface.ErrInvalidBound, entity.GetID(), bound)
+	}
+	for i := range bound.Min {
+		if bound.Min[i] > bound.Max[i] {
+			return fmt.Errorf("%w: entity %d, %v", siface.ErrInvalidBound, entity.GetID(), bound)
+		}
+	}
+	return nil
+}
+
+// DuplicateID returns siface.ErrDuplicateID wrapped with the ID.
+func DuplicateID(entityId int64) error {
+	return fmt.Errorf("%w: entity %d", siface.ErrDuplicateID, entityId)
+}
+
+// NotFound returns siface.ErrNotFound wrapped with the ID.
+func NotFound(entityId int64) error {
+	return fmt.Errorf("%w: entity %d", siface.ErrNotFound, entityId)
+}
+
+// OutOfBounds returns siface.ErrOutOfBounds wrapped with the ID and the location of the entity.
+func OutOfBounds(entity siface.ISpatial) error {
+	return fmt.Errorf("%w: entity %d at %v", siface.ErrOutOfBounds, entity.GetID(), entity.GetLocation())
+}
+
+// Admissible splits the entities to be added in a batch, the ones with an invalid bound are dropped.
+//
+// Parameters:
+// - entities: the entities to be added in a batch.
+// - contains: checks if an entity with the ID is in the search tree.
+//
+// Returns the entities with new IDs, the first of the ones sharing an ID, which can be added in one pass,
+// and the rest, whose ID is in the search tree or the batch, to be added one by one by AddE, see AddEach.
+func Admissible(entities []siface.ISpatial, contains func(entityId int64) bool) ([]siface.ISpatial, []siface.ISpatial) {
+	fresh := make([]siface.ISpatial, 0, len(entities))
+	var duplicates []siface.ISpatial
+	seen := make(map[int64]struct{}, len(entities))
+	for _, entity := range entities {
+		if CheckBound(entity) != nil {
+			continue
+		}
+		if _, ok := seen[entity.GetID()]; ok || contains(entity.GetID()) {
+			duplicates = append(duplicates, entity)
+			continue
+		}
+		seen[entity.GetID()] = struct{}{}
+		fresh = append(fresh, entity)
+	}
+	return fresh, duplicates
+}
@@ -0,0 +1,58 @@
+// Package tree .
+package tree
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// Extent tracks how far the bounds of the entities reach beyond their locations.
+//
+// Trees index entities by location, so a search for the entities whose bound intersects a box
+// has to cover the box widened by the extent. The extent only grows, it's an upper bound.
+type Extent struct {
+	reach [3]int32
+}
+
+// Grow widens the extent to cover the bound of the entity.
+func (e *Extent) Grow(entity siface.ISpatial) {
+	location, bound := entity.GetLocation(), BoundOf(entity)
+	for i := range e.reach {
+		e.reach[i] = max(e.reach[i], location[i]-bound.Min[i], bound.Max[i]-location[i])
+	}
+}
+
+// Expand returns the bound widened by the extent.
+func (e *Extent) Expand(bound bounds.Bound) bounds.Bound {
+	return bounds.NewBound(
+		geo.NewVec3Int(bound.Min.X()-e.reach[0], bound.Min.Y()-e.reach[1], bound.Min.Z()-e.reach[2]),
+		geo.NewVec3Int(bound.Max.X()+e.reach[0], bound.Max.Y()+e.reach[1], bound.Max.Z()+e.reach[2]),
+	)
+}
+
+// BoundOf returns the bound of the entity, an entity without bound is treated as a point at its location.
+func BoundOf(entity siface.ISpatial) bounds.Bound {
+	if bound := entity.GetBound(); len(bound.Min) == 3 && len(bound.Max) == 3 {
+		return bound
+	}
+	return bounds.NewBound(entity.GetLocation(), entity.GetLocation())
+}
+
+// CollisionPairs returns all pairs of entities whose bounds overlap, each pair is reported once.
+//
+// Parameters:
+// - rangeEntities: ranges all the entities of the index.
+// - intersecting: finds the entities whose bound intersects a bound.
+func CollisionPairs(rangeEntities func(f func(entity siface.ISpatial) bool), intersecting func(bound bounds.Bound) []siface.ISpatial) [][2]siface.ISpatial {
+	pairs := make([][2]siface.ISpatial, 0)
+	rangeEntities(func(entity siface.ISpatial) bool {
+		for _, other := range intersecting(BoundOf(entity)) {
+			if other.GetID() > entity.GetID() {
+				pairs = append(pairs, [2]siface.ISpatial{entity, other})
+			}
+		}
+		return true
+	})
+	return pairs
+}
@@ -0,0 +1,639 @@
+// Package grid provides an implementation of a uniform grid(spatial hash) for spatial partitioning.
+package grid
+
+import (
+	"fmt"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/filter"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// Grid represents a uniform grid, the bound is divided into cells of a fixed size.
+//
+// A 2D grid divides the x and z axes only, like a quadtree, its cells span all the y axis.
+type Grid struct {
+	bound    bounds.Bound
+	dim      consts.Dim
+	cellSize [3]int32
+	counts   [3]int64           // Number of cells along each axis.
+	cells    map[[3]int64]*cell // The occupied cells by their coordinates, created on demand and dropped once empty.
+	entities map[int64]*cell    // Map of entity IDs to the cell holding them.
+	extent   tree.Extent        // How far the bounds of the entities reach beyond their locations.
+	option   *option.OptionalSettings
+}
+
+// cell holds the entities located within it.
+type cell struct {
+	coord    [3]int64
+	entities map[int64]siface.ISpatial
+}
+
+// NewGrid creates a new Grid.
+// Parameters:
+// - bound: the spatial boundaries of the grid.
+// - cellSize: the size of the cells, the grid is 2D if the y of cellSize is not positive.
+// - optional: variadic optional parameters to configure the grid, mergeIf doesn't apply.
+func NewGrid(bound bounds.Bound, cellSize geo.Vec3Int, optional ...option.Optional) (*Grid, error) {
+	if cellSize.X() <= 0 || cellSize.Z() <= 0 {
+		return nil, fmt.Errorf("cell size should be greater than 0")
+	}
+	g := &Grid{
+		bound:    bound,
+		dim:      consts.Dim3,
+		cellSize: [3]int32{cellSize.X(), cellSize.Y(), cellSize.Z()},
+		cells:    make(map[[3]int64]*cell),
+		entities: make(map[int64]*cell),
+		option:   option.OptionalDefault(),
+	}
+	if cellSize.Y() <= 0 {
+		g.dim = consts.Dim2
+	}
+	for i := range g.counts {
+		g.counts[i] = 1
+		if g.axis(i) {
+			size := int64(bound.Max[i]) - int64(bound.Min[i])
+			if size < 0 {
+				return nil, fmt.Errorf("invalid bound")
+			}
+			g.counts[i] = max(1, (size+int64(g.cellSize[i])-1)/int64(g.cellSize[i]))
+		}
+	}
+	for _, opt := range optional {
+		opt(g.option)
+	}
+	return g, nil
+}
+
+// Add adds an entity to the grid.
+// Returns true if the entity was added successfully, false if it's outside the bound of the grid, see AddE.
+func (g *Grid) Add(entity siface.ISpatial) bool {
+	return g.AddE(entity) == nil
+}
+
+// AddE adds an entity to the grid.
+// Returns siface.ErrInvalidBound, siface.ErrDuplicateID if the duplicate policy rejects it, or siface.ErrOutOfBounds if it's outside the bound of the grid.
+func (g *Grid) AddE(entity siface.ISpatial) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	if g.Contains(entity.GetID()) {
+		return tree.AddDuplicate(g, entity, g.option.Duplicate())
+	}
+	if !g.contains(entity.GetLocation()) {
+		return tree.OutOfBounds(entity)
+	}
+	c := g.cellAt(g.coordOf(entity.GetLocation()), true)
+	c.entities[entity.GetID()] = entity
+	g.entities[entity.GetID()] = c
+	g.extent.Grow(entity)
+	return nil
+}
+
+// AddBatch adds the entities to the grid, it's the same as adding them one by one as the cells never split.
+// Returns the number of entities added, the ones whose ID is in the tree or repeated in the batch are added one by one
+// by the duplicate policy, see option.WithDuplicate, and the ones rejected by AddE are skipped.
+func (g *Grid) AddBatch(entities []siface.ISpatial) int {
+	added := 0
+	for _, entity := range entities {
+		if g.Add(entity) {
+			added++
+		}
+	}
+	return added
+}
+
+// Remove removes an entity from the grid by its ID.
+// Returns true if the entity was removed successfully, false otherwise.
+func (g *Grid) Remove(entityId int64) bool {
+	return g.RemoveE(entityId) == nil
+}
+
+// RemoveE removes an entity from the grid by its ID, returns siface.ErrNotFound if it's not in the grid.
+func (g *Grid) RemoveE(entityId int64) error {
+	if c, ok := g.entities[entityId]; ok {
+		g.leave(c, entityId)
+		delete(g.entities, entityId)
+		return nil
+	}
+	return tree.NotFound(entityId)
+}
+
+// Get returns the entity with the given ID.
+func (g *Grid) Get(entityId int64) (siface.ISpatial, bool) {
+	if c, ok := g.entities[entityId]; ok {
+		return c.entities[entityId], true
+	}
+	return nil, false
+}
+
+// Contains checks if an entity with the given ID is in the grid.
+func (g *Grid) Contains(entityId int64) bool {
+	_, ok := g.entities[entityId]
+	return ok
+}
+
+// Update moves an entity to the cell of its new location in constant time.
+// oldLocation is not needed by the grid, the cell holding the entity is looked up by ID.
+// Returns false if the entity is unknown or its new location is outside the bound of the grid,
+// in which case the grid is unchanged.
+func (g *Grid) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	return g.UpdateE(entity, oldLocation) == nil
+}
+
+// UpdateE moves an entity like Update.
+// Returns siface.ErrInvalidBound, siface.ErrNotFound, or siface.ErrOutOfBounds if its new location is outside the bound of the grid.
+func (g *Grid) UpdateE(entity siface.ISpatial, _ geo.Vec3Int) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	old, ok := g.entities[entity.GetID()]
+	if !ok {
+		return tree.NotFound(entity.GetID())
+	}
+	if !g.contains(entity.GetLocation()) {
+		return tree.OutOfBounds(entity)
+	}
+	if c := g.cellAt(g.coordOf(entity.GetLocation()), true); c != old {
+		g.leave(old, entity.GetID())
+		g.entities[entity.GetID()] = c
+	}
+	g.entities[entity.GetID()].entities[entity.GetID()] = entity
+	g.extent.Grow(entity)
+	return nil
+}
+
+// GetSurroundingEntities finds entities within a certain radius of a center point.
+// Parameters:
+// - center: the center point to search around.
+// - radius: the radius within which to search for entities.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities within the specified radius.
+func (g *Grid) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return g.AppendSurroundingEntities(make([]siface.ISpatial, 0), center, radius, filters...)
+}
+
+// AppendSurroundingEntities appends the entities within a certain radius of a center point to dst,
+// and returns the extended slice, like GetSurroundingEntities without allocating a new one.
+func (g *Grid) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	g.visitSurroundingEntities(center, radius, g.option.Metric(), func(entity siface.ISpatial) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters)
+	return dst
+}
+
+// VisitSurroundingEntities calls visit for each entity within a certain radius of a center point,
+// until visit returns false.
+func (g *Grid) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	g.visitSurroundingEntities(center, radius, g.option.Metric(), visit, filters)
+}
+
+// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric,
+// a 2D grid ignores y. The euclidean distance is used if the metric is nil.
+func (g *Grid) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	g.visitSurroundingEntities(center, radius, metric, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	}, filters)
+	return ret
+}
+
+// visitSurroundingEntities calls visit for each entity within radius of the center measured by the metric, until visit returns false.
+func (g *Grid) visitSurroundingEntities(center []float32, radius float32, metric util.DistanceMetric, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) {
+	var buf [3]int32
+	c := g.option.ScaleTo(buf[:], center)
+	if metric == nil {
+		r := int32(math.Ceil(float64(radius)))
+		cMin := [3]int32{c[0] - r, c[1] - r, c[2] - r}
+		cMax := [3]int32{c[0] + r, c[1] + r, c[2] + r}
+		within := func(entity siface.ISpatial) bool {
+			return g.distanceSquared(entity.GetLocation(), c) <= float64(radius)*float64(radius)
+		}
+		g.visit(bounds.Bound{Min: cMin[:], Max: cMax[:]}, within, filters, visit)
+		return
+	}
+	axes := []int{0, 1, 2}
+	if g.dim == consts.Dim2 {
+		axes = []int{0, 2}
+	}
+	mq := tree.NewMetricQuery(metric, c, radius, axes)
+	within := func(entity siface.ISpatial) bool {
+		return mq.Within(entity.GetLocation())
+	}
+	g.visit(mq.Bound(), within, filters, visit)
+}
+
+// GetNearest finds the k entities nearest to a center point, sorted by ascending distance.
+// maxDistance limits the distance of the entities, there is no limit if it's not positive.
+//
+// The cells are visited ring by ring around the cell of the center, until no closer entity can be found.
+func (g *Grid) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	type candidate struct {
+		entity siface.ISpatial
+		dist   float64
+	}
+	ret := make([]siface.ISpatial, 0)
+	if k <= 0 {
+		return ret
+	}
+	c := g.option.ScaleFunc(center)
+	origin := g.coordOf(g.clamp(c))
+	minSize := int64(math.MaxInt32)
+	rings := int64(0)
+	for i := range g.counts {
+		if g.axis(i) {
+			minSize = min(minSize, int64(g.cellSize[i]))
+			rings = max(rings, origin[i], g.counts[i]-1-origin[i])
+		}
+	}
+	candidates := make([]candidate, 0)
+	for ring := int64(0); ring <= rings; ring++ {
+		// the cells on the ring are at least (ring-1) cells away from the center.
+		if reach := float64(max(0, ring-1)) * float64(minSize); ring > 0 {
+			if maxDistance > 0 && reach > float64(maxDistance) {
+				break
+			}
+			if len(candidates) >= k && candidates[k-1].dist <= reach*reach {
+				break
+			}
+		}
+		// once the rings span more coordinates than the occupied cells, the remaining ones are scanned at once.
+		far := ring
+		if from, to := g.ringBox(origin, ring); volume(from, to) > float64(len(g.cells)) {
+			far = rings
+		}
+		g.rangeRings(origin, ring, far, func(cl *cell) {
+			for _, entity := range cl.entities {
+				dist := g.distanceSquared(entity.GetLocation(), c)
+				if maxDistance > 0 && dist > float64(maxDistance)*float64(maxDistance) {
+					continue
+				}
+				if filter.Match(entity, filters...) {
+					candidates = append(candidates, candidate{entity: entity, dist: dist})
+				}
+			}
+		})
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].dist < candidates[j].dist
+		})
+		if len(candidates) > k {
+			candidates = candidates[:k]
+		}
+		ring = far
+	}
+	for _, cd := range candidates {
+		ret = append(ret, cd.entity)
+	}
+	return ret
+}
+
+// GetEntitiesInBound finds entities whose location is within a box, a 2D grid ignores y.
+func (g *Grid) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	inBound := func(entity siface.ISpatial) bool {
+		if g.dim == consts.Dim2 {
+			return bound.Contains2D(entity.GetLocation())
+		}
+		return bound.Contains(entity.GetLocation())
+	}
+	return g.find(bound, inBound, filters)
+}
+
+// GetIntersecting finds entities whose bound intersects a box, a 2D grid ignores y.
+func (g *Grid) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	intersects := func(entity siface.ISpatial) bool {
+		if g.dim == consts.Dim2 {
+			return tree.BoundOf(entity).Intersects2D(bound)
+		}
+		return tree.BoundOf(entity).Intersects(bound)
+	}
+	return g.find(g.extent.Expand(bound), intersects, filters)
+}
+
+// GetCollisionPairs finds all pairs of entities whose bounds overlap.
+func (g *Grid) GetCollisionPairs() [][2]siface.ISpatial {
+	return tree.CollisionPairs(g.rangeEntities, func(bound bounds.Bound) []siface.ISpatial {
+		return g.GetIntersecting(bound)
+	})
+}
+
+// All returns an iterator over all the entities in the grid.
+func (g *Grid) All() iter.Seq[siface.ISpatial] {
+	return g.rangeEntities
+}
+
+// Within returns an iterator over the entities within a certain radius of a center point,
+// the search stops when the loop breaks.
+func (g *Grid) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		g.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+// Len returns the number of entities in the grid.
+func (g *Grid) Len() int {
+	return len(g.entities)
+}
+
+// Stats returns the statistics of the grid, the cells are the leaves at depth 0 and never overflow.
+func (g *Grid) Stats() siface.Stats {
+	stats := siface.Stats{
+		Entities:       len(g.entities),
+		Nodes:          len(g.cells),
+		DepthHistogram: []int{0},
+		MemoryBytes:    int(unsafe.Sizeof(*g)) + 2*tree.MapSize + (len(g.cells)+len(g.entities))*tree.MapEntrySize,
+	}
+	for _, c := range g.cells {
+		stats.MemoryBytes += int(unsafe.Sizeof(*c)) + tree.MapSize + len(c.entities)*tree.MapEntrySize
+		if len(c.entities) > 0 {
+			stats.Leaves++
+			stats.MaxLeafEntities = max(stats.MaxLeafEntities, len(c.entities))
+		}
+	}
+	stats.DepthHistogram[0] = stats.Leaves
+	if stats.Leaves > 0 {
+		stats.AvgLeafEntities = float64(stats.Entities) / float64(stats.Leaves)
+	}
+	return stats
+}
+
+// Snapshot writes the config and the entities of the grid to w, see Restore.
+func (g *Grid) Snapshot(w io.Writer) error {
+	e := tree.NewEncoder(w, tree.KindGrid)
+	e.Bound(g.bound)
+	e.Vec(g.cellSize[:])
+	tree.EncodeEntities(e, len(g.entities), g.rangeEntities)
+	return e.Close()
+}
+
+// Restore restores a grid from a snapshot written by Snapshot, the entities are added at their current locations.
+// Parameters:
+// - d: the decoder of the snapshot, whose header has been read.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil.
+// - optional: variadic optional parameters to configure the grid.
+func Restore(d *tree.Decoder, resolve func(id int64) siface.ISpatial, optional ...option.Optional) (*Grid, error) {
+	bound, cellSize := d.Bound(), d.Vec()
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	g, err := NewGrid(bound, cellSize, optional...)
+	if err != nil {
+		return nil, err
+	}
+	entities := tree.DecodeEntities(d, resolve)
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	for _, entity := range entities {
+		g.Add(entity)
+	}
+	return g, nil
+}
+
+// ToDot generates a dot file for the grid, with the non-empty cells as the children of the root.
+func (g *Grid) ToDot() error {
+	const fileName = "grid.dot"
+	if g.option.DrawPath() == "" {
+		return fmt.Errorf("draw path not set")
+	}
+	if file, err := os.OpenFile(path.Join(g.option.DrawPath(), fileName), os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	} else {
+		defer file.Close()
+		return tree.WriteDot(tree.GetTemplate(), g.toPNode(), file)
+	}
+}
+
+// toPNode converts the grid to the nodes of the dot template.
+func (g *Grid) toPNode() *tree.PNode {
+	root := &tree.Elem{Name: "root", Label: "root"}
+	p := &tree.PNode{
+		Nodes:    []*tree.Elem{root},
+		Entities: make([]*tree.Elem, 0),
+		Edges:    make([]*tree.Pair, 0),
+	}
+	coords := make([][3]int64, 0, len(g.cells))
+	for coord := range g.cells {
+		coords = append(coords, coord)
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		return slices.Compare(coords[i][:], coords[j][:]) < 0
+	})
+	for _, coord := range coords {
+		c := g.cells[coord]
+		node := &tree.Elem{
+			Name:  fmt.Sprintf("cell_%d_%d_%d", c.coord[0], c.coord[1], c.coord[2]),
+			Label: fmt.Sprintf("cell_%d_%d_%d", c.coord[0], c.coord[1], c.coord[2]),
+		}
+		p.Nodes = append(p.Nodes, node)
+		p.Edges = append(p.Edges, &tree.Pair{Parent: root, Child: node})
+		ids := make([]int64, 0, len(c.entities))
+		for id := range c.entities {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			el := &tree.Elem{
+				Name:  fmt.Sprintf("entity_%d", id),
+				Label: strconv.Itoa(int(id)),
+			}
+			p.Entities = append(p.Entities, el)
+			p.Edges = append(p.Edges, &tree.Pair{Parent: node, Child: el})
+		}
+	}
+	return p
+}
+
+// find collects the entities accepted within the cells overlapping the bound.
+func (g *Grid) find(bound bounds.Bound, accept func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	g.visit(bound, accept, filters, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	})
+	return ret
+}
+
+// visit calls f for each entity accepted within the cells overlapping the bound, until f returns false.
+func (g *Grid) visit(bound bounds.Bound, accept func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool, f func(entity siface.ISpatial) bool) {
+	from, to, ok := g.coordRange(bound)
+	if !ok {
+		return
+	}
+	g.rangeCells(from, to, func(c *cell) bool {
+		for _, entity := range c.entities {
+			if accept(entity) && filter.Match(entity, filters...) && !f(entity) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// rangeRings ranges the occupied cells whose Chebyshev distance to the origin cell is between near and far.
+func (g *Grid) rangeRings(origin [3]int64, near, far int64, f func(c *cell)) {
+	from, to := g.ringBox(origin, far)
+	g.rangeCells(from, to, func(c *cell) bool {
+		ring := int64(0)
+		for i := range origin {
+			ring = max(ring, c.coord[i]-origin[i], origin[i]-c.coord[i])
+		}
+		if ring >= near {
+			f(c)
+		}
+		return true
+	})
+}
+
+// ringBox returns the range of the coordinates of the cells within the ring around the origin cell.
+func (g *Grid) ringBox(origin [3]int64, ring int64) ([3]int64, [3]int64) {
+	var from, to [3]int64
+	for i := range origin {
+		from[i] = max(0, origin[i]-ring)
+		to[i] = min(g.counts[i]-1, origin[i]+ring)
+	}
+	return from, to
+}
+
+// rangeCells ranges the occupied cells within the range of the coordinates, until f returns false.
+// The cells are looked up coordinate by coordinate, or all scanned if there are fewer of them than the coordinates.
+func (g *Grid) rangeCells(from, to [3]int64, f func(c *cell) bool) {
+	if volume(from, to) > float64(len(g.cells)) {
+		for _, c := range g.cells {
+			if c.within(from, to) && !f(c) {
+				return
+			}
+		}
+		return
+	}
+	for x := from[0]; x <= to[0]; x++ {
+		for y := from[1]; y <= to[1]; y++ {
+			for z := from[2]; z <= to[2]; z++ {
+				if c, ok := g.cells[[3]int64{x, y, z}]; ok && !f(c) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rangeEntities ranges all the entities in the grid.
+func (g *Grid) rangeEntities(f func(entity siface.ISpatial) bool) {
+	for id, c := range g.entities {
+		if !f(c.entities[id]) {
+			return
+		}
+	}
+}
+
+// axis checks if the grid is divided along the axis.
+func (g *Grid) axis(i int) bool {
+	return g.dim == consts.Dim3 || i != 1
+}
+
+// contains checks if the location is within the bound of the grid.
+func (g *Grid) contains(location geo.Vec3Int) bool {
+	if g.dim == consts.Dim2 {
+		return g.bound.Contains2D(location)
+	}
+	return g.bound.Contains(location)
+}
+
+// clamp clamps the location into the bound of the grid.
+func (g *Grid) clamp(location geo.Vec3Int) geo.Vec3Int {
+	ret := geo.NewVec3Int(0, 0, 0)
+	for i := range ret {
+		ret[i] = min(max(location[i], g.bound.Min[i]), g.bound.Max[i])
+	}
+	return ret
+}
+
+// coordOf returns the coordinates of the cell holding the location, which must be within the grid.
+func (g *Grid) coordOf(location geo.Vec3Int) [3]int64 {
+	var coord [3]int64
+	for i := range coord {
+		if g.axis(i) {
+			coord[i] = min(g.counts[i]-1, (int64(location[i])-int64(g.bound.Min[i]))/int64(g.cellSize[i]))
+		}
+	}
+	return coord
+}
+
+// coordRange returns the range of the coordinates of the cells overlapping the bound.
+func (g *Grid) coordRange(bound bounds.Bound) ([3]int64, [3]int64, bool) {
+	var from, to [3]int64
+	for i := range from {
+		if !g.axis(i) {
+			continue
+		}
+		if bound.Max[i] < g.bound.Min[i] || bound.Min[i] > g.bound.Max[i] {
+			return from, to, false
+		}
+		from[i] = g.coordOf(g.clamp(bound.Min))[i]
+		to[i] = g.coordOf(g.clamp(bound.Max))[i]
+	}
+	return from, to, true
+}
+
+// cellAt returns the cell at the coordinates, it's created if create is true.
+func (g *Grid) cellAt(coord [3]int64, create bool) *cell {
+	c, ok := g.cells[coord]
+	if !ok && create {
+		c = &cell{coord: coord, entities: make(map[int64]siface.ISpatial)}
+		g.cells[coord] = c
+	}
+	return c
+}
+
+// leave removes the entity from the cell, the cell is dropped once empty.
+func (g *Grid) leave(c *cell, entityId int64) {
+	delete(c.entities, entityId)
+	if len(c.entities) == 0 {
+		delete(g.cells, c.coord)
+	}
+}
+
+// distanceSquared returns the squared distance between two locations, a 2D grid ignores y.
+func (g *Grid) distanceSquared(p1, p2 geo.Vec3Int) float64 {
+	sum := 0.0
+	for i := 0; i < 3; i++ {
+		if g.axis(i) {
+			d := float64(p1[i]) - float64(p2[i])
+			sum += d * d
+		}
+	}
+	return sum
+}
+
+// within checks if the coordinates of the cell are within the range.
+func (c *cell) within(from, to [3]int64) bool {
+	for i := range c.coord {
+		if c.coord[i] < from[i] || c.coord[i] > to[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// volume returns the number of the coordinates within the range, as a float64 not to overflow.
+func volume(from, to [3]int64) float64 {
+	ret := 1.0
+	for i := range from {
+		ret *= float64(to[i] - from[i] + 1)
+	}
+	return ret
+}
@@ -0,0 +1,147 @@
+// Package grid .
+package grid
+
+import (
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"testing"
+)
+
+func TestGrid_creation(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	g, err := NewGrid(bound, geo.NewVec3Int(10, 10, 10))
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int64{10, 10, 10}, g.counts)
+	g, err = NewGrid(bound, geo.NewVec3Int(30, 0, 30))
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int64{4, 1, 4}, g.counts)
+	_, err = NewGrid(bound, geo.NewVec3Int(0, 10, 10))
+	assert.NotNil(t, err)
+}
+
+func TestGrid_Sparse(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(-2e9, -2e9, -2e9), geo.NewVec3Int(2e9, 2e9, 2e9))
+	g, err := NewGrid(bound, geo.NewVec3Int(1, 1, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int64{4e9, 4e9, 4e9}, g.counts)
+	far := mocks.CreateMockSpatial(1, -2e9, 2e9, 0)
+	near := mocks.CreateMockSpatial(2, 10, 10, 10)
+	assert.True(t, g.Add(far))
+	assert.True(t, g.Add(near))
+	assert.Equal(t, 2, g.Stats().Nodes)
+	assert.Equal(t, []siface.ISpatial{near}, g.GetSurroundingEntities([]float32{0, 0, 0}, 20))
+	assert.Equal(t, 2, len(g.GetEntitiesInBound(bound)))
+	assert.Equal(t, []siface.ISpatial{near, far}, g.GetNearest([]float32{0, 0, 0}, 2, 0))
+
+	// the cells are dropped once empty.
+	assert.True(t, g.Update(mocks.CreateMockSpatial(2, 20, 20, 20), near.GetLocation()))
+	assert.True(t, g.Remove(1))
+	assert.Equal(t, 1, g.Stats().Nodes)
+	assert.Equal(t, 1, len(g.GetNearest([]float32{0, 0, 0}, 2, 0)))
+}
+
+func TestGrid_AddRemoveUpdate(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	g, _ := NewGrid(bound, geo.NewVec3Int(10, 10, 10))
+	entity := mocks.CreateMockSpatial(1, 5, 5, 5)
+	assert.True(t, g.Add(entity))
+	assert.False(t, g.Add(mocks.CreateMockSpatial(2, 101, 5, 5)))
+	assert.True(t, g.Add(mocks.CreateMockSpatial(3, 100, 100, 100))) // on the boundary
+	assert.True(t, g.Contains(1))
+
+	moved := mocks.CreateMockSpatial(1, 55, 5, 5)
+	assert.True(t, g.Update(moved, entity.GetLocation()))
+	assert.Empty(t, g.GetSurroundingEntities([]float32{5, 5, 5}, 1))
+	assert.Equal(t, []siface.ISpatial{moved}, g.GetSurroundingEntities([]float32{55, 5, 5}, 1))
+	got, ok := g.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, moved, got)
+	assert.False(t, g.Update(mocks.CreateMockSpatial(1, 500, 5, 5), moved.GetLocation()))
+
+	assert.True(t, g.Remove(1))
+	assert.False(t, g.Remove(1))
+	assert.False(t, g.Update(moved, moved.GetLocation()))
+}
+
+func TestGrid_QueriesMatchBruteForce(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for _, cellSize := range []geo.Vec3Int{geo.NewVec3Int(7, 7, 7), geo.NewVec3Int(7, 0, 7)} {
+		g, _ := NewGrid(bound, cellSize)
+		dist := util.Distance3D
+		if cellSize.Y() == 0 {
+			dist = util.Distance2D
+		}
+		r := rand.New(rand.NewSource(1))
+		entities := make([]siface.ISpatial, 0)
+		for i := 0; i < 300; i++ {
+			entity := mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101))
+			entities = append(entities, entity)
+			g.Add(entity)
+		}
+		center := []float32{30, 40, 50}
+		expected := make([]siface.ISpatial, 0)
+		for _, entity := range entities {
+			if dist(entity.GetLocation().ToFloat32(), center) <= 20 {
+				expected = append(expected, entity)
+			}
+		}
+		assert.ElementsMatch(t, expected, g.GetSurroundingEntities(center, 20))
+
+		sort.SliceStable(entities, func(i, j int) bool {
+			return dist(entities[i].GetLocation().ToFloat32(), center) < dist(entities[j].GetLocation().ToFloat32(), center)
+		})
+		nearest := g.GetNearest(center, 10, 0)
+		assert.Len(t, nearest, 10)
+		for i, entity := range nearest {
+			assert.Equal(t, dist(entities[i].GetLocation().ToFloat32(), center), dist(entity.GetLocation().ToFloat32(), center))
+		}
+		for _, entity := range g.GetNearest(center, 300, 15) {
+			assert.LessOrEqual(t, dist(entity.GetLocation().ToFloat32(), center), float32(15))
+		}
+	}
+}
+
+func TestGrid_2DIgnoresY(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 0, 100))
+	g, _ := NewGrid(bound, geo.NewVec3Int(10, 0, 10))
+	entity := mocks.CreateMockSpatial(1, 10, 500, 10)
+	assert.True(t, g.Add(entity))
+	assert.Equal(t, []siface.ISpatial{entity}, g.GetSurroundingEntities([]float32{10, 0, 10}, 1))
+	assert.Equal(t, []siface.ISpatial{entity}, g.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 0, 10))))
+}
+
+func TestGrid_GetIntersecting(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	g, _ := NewGrid(bound, geo.NewVec3Int(10, 10, 10))
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10, bounds.NewBound(geo.NewVec3Int(5, 5, 5), geo.NewVec3Int(15, 15, 15)))
+	entity2 := mocks.CreateMockSpatial(2, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(15, 15, 15), geo.NewVec3Int(85, 85, 85)))
+	entity3 := mocks.CreateMockSpatial(3, 95, 95, 95)
+	g.Add(entity1)
+	g.Add(entity2)
+	g.Add(entity3)
+	ret := g.GetIntersecting(bounds.NewBound(geo.NewVec3Int(80, 80, 80), geo.NewVec3Int(100, 100, 100)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity2, entity3}, ret)
+	assert.Equal(t, [][2]siface.ISpatial{{entity1, entity2}}, g.GetCollisionPairs())
+}
+
+func TestGrid_ToDot(t *testing.T) {
+	dir := t.TempDir()
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 0, 100))
+	g, _ := NewGrid(bound, geo.NewVec3Int(50, 0, 50), option.WithDrawPath(dir))
+	g.Add(mocks.CreateMockSpatial(1, 10, 0, 10))
+	g.Add(mocks.CreateMockSpatial(2, 60, 0, 60))
+	assert.Nil(t, g.ToDot())
+	content, err := os.ReadFile(path.Join(dir, "grid.dot"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "root -> cell_0_0_0")
+	assert.Contains(t, string(content), "cell_1_0_1 -> entity_2")
+}
@@ -0,0 +1,95 @@
+// Package tree .
+package tree
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/util"
+	"math"
+	"slices"
+)
+
+// ReachBound returns the box around the center covering the reach of a metric, see util.DistanceMetric.Reach.
+// The box is clamped to the range of int32, so an axis not limited covers the whole axis.
+func ReachBound(center geo.Vec3Int, reach [3]float32) bounds.Bound {
+	bMin, bMax := geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(0, 0, 0)
+	for i := range reach {
+		r := math.Ceil(float64(reach[i]))
+		bMin[i] = clampInt32(float64(center[i]) - r)
+		bMax[i] = clampInt32(float64(center[i]) + r)
+	}
+	return bounds.NewBound(bMin, bMax)
+}
+
+// MetricQuery checks if the locations are within a distance of a center measured by a metric.
+// Only the axes indexed are measured, the locations take the coordinates of the center on the others.
+// The metrics take y as the vertical axis, so on a plane of x and y, y is measured as z and z as y.
+//
+// It holds the buffer of the location measured, so it's used by one query at a time.
+type MetricQuery struct {
+	metric     util.DistanceMetric
+	center     [3]float32 // The center on the axes of the metric.
+	p          [3]float32 // The location measured, on the axes of the metric.
+	distance   float32
+	axes       []int
+	metricAxes [3]int // The axis of the metric each axis of the locations is measured as.
+}
+
+// NewMetricQuery creates a MetricQuery.
+// Parameters:
+// - metric: the metric to measure the distance.
+// - center: the center of the query.
+// - distance: the maximum distance of the locations.
+// - axes: the axes indexed.
+func NewMetricQuery(metric util.DistanceMetric, center geo.Vec3Int, distance float32, axes []int) *MetricQuery {
+	q := &MetricQuery{
+		metric:     metric,
+		distance:   distance,
+		axes:       axes,
+		metricAxes: [3]int{0, 1, 2},
+	}
+	if !slices.Contains(axes, 2) {
+		// z is the vertical axis of the plane.
+		q.metricAxes = [3]int{0, 2, 1}
+	}
+	for i := range center {
+		q.center[q.metricAxes[i]] = float32(center[i])
+	}
+	return q
+}
+
+// Bound returns the box around the center covering the reach of the metric, see ReachBound.
+func (q *MetricQuery) Bound() bounds.Bound {
+	c := geo.NewVec3Int(0, 0, 0)
+	var reach [3]float32
+	metricReach := q.metric.Reach(q.distance)
+	for i := range reach {
+		c[i] = int32(q.center[q.metricAxes[i]])
+		reach[i] = metricReach[q.metricAxes[i]]
+	}
+	return ReachBound(c, reach)
+}
+
+// Within checks if the location is within the distance of the center.
+func (q *MetricQuery) Within(location geo.Vec3Int) bool {
+	q.p = q.center
+	for _, axis := range q.axes {
+		q.p[q.metricAxes[axis]] = float32(location[axis])
+	}
+	return q.metric.Within(q.p[:], q.center[:], q.distance)
+}
+
+// WithinBound checks if the point of the bound closest to the center is within the distance of the center.
+func (q *MetricQuery) WithinBound(bound bounds.Bound) bool {
+	q.p = q.center
+	for _, axis := range q.axes {
+		i := q.metricAxes[axis]
+		q.p[i] = min(max(q.p[i], float32(bound.Min[axis])), float32(bound.Max[axis]))
+	}
+	return q.metric.Within(q.p[:], q.center[:], q.distance)
+}
+
+// clampInt32 converts v to int32, clamped to the range of int32.
+func clampInt32(v float64) int32 {
+	return int32(max(math.MinInt32, min(math.MaxInt32, v)))
+}
@@ -8,14 +8,24 @@ import (
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/treenode"
 	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
 	"os"
 	"path"
+	"slices"
 )
 
 // Octree represents an octree data structure.
+//
+// A loose octree(see option.WithLoose) indexes the entities by their bounds, the queries measure their bounds instead of their locations.
+// The entities out of the bound are rejected, unless another policy is set by option.WithOutOfBounds, like growing the octree.
 type Octree struct {
-	root   *treenode.TreeNode // The root node of the octree.
+	root   *treenode.TreeNode           // The root node of the octree.
+	leaves map[int64]*treenode.TreeNode // Map of entity IDs to the leaf holding them.
+	extent tree.Extent                  // How far the bounds of the entities reach beyond their locations.
 	option *option.OptionalSettings
 }
 
@@ -31,11 +41,16 @@ func NewOctree(bound bounds.Bound, maxDepth int, capacity int, optional ...optio
 	} else {
 		o := &Octree{
 			root:   root,
+			leaves: make(map[int64]*treenode.TreeNode),
 			option: option.OptionalDefault(),
 		}
+		root.SetLeafIndex(o.leaves)
 		for _, opt := range optional {
 			opt(o.option)
 		}
+		if err = root.SetLoose(o.option.Loose()); err != nil {
+			return nil, err
+		}
 		return o, nil
 	}
 }
@@ -43,9 +58,54 @@ func NewOctree(bound bounds.Bound, maxDepth int, capacity int, optional ...optio
 // Add adds an entity to the octree.
 // Parameters:
 // - entity: the spatial entity to be added.
-// Returns true if the entity was added successfully, false otherwise.
+// Returns true if the entity was added successfully, false otherwise, see AddE.
 func (o *Octree) Add(entity siface.ISpatial) bool {
-	return o.root.Add(entity)
+	return o.AddE(entity) == nil
+}
+
+// AddE adds an entity to the octree.
+// The entity out of the bound of the octree is added by the policy, see option.WithOutOfBounds.
+// Parameters:
+// - entity: the spatial entity to be added.
+// Returns siface.ErrInvalidBound, siface.ErrDuplicateID if the duplicate policy rejects it, or siface.ErrOutOfBounds if the out-of-bounds policy rejects it.
+func (o *Octree) AddE(entity siface.ISpatial) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	if o.Contains(entity.GetID()) {
+		return tree.AddDuplicate(o, entity, o.option.Duplicate())
+	}
+	if !o.root.Add(entity) && !o.addOutOfBounds(entity) {
+		return tree.OutOfBounds(entity)
+	}
+	o.extent.Grow(entity)
+	return nil
+}
+
+// addOutOfBounds adds the entity out of the bound of the octree by the policy, the root is replaced if the octree grows.
+func (o *Octree) addOutOfBounds(entity siface.ISpatial) bool {
+	root, ok := tree.AddOutOfBounds(o.root, entity, o.option.OutOfBounds())
+	o.root = root
+	return ok
+}
+
+// AddBatch adds the entities to the octree top-down in one pass, faster than adding them one by one.
+// Parameters:
+// - entities: the spatial entities to be added, the ones out of the bounds of the octree are added one by one by the policy.
+// Returns the number of entities added, the ones whose ID is in the tree or repeated in the batch are added one by one
+// by the duplicate policy, see option.WithDuplicate, and the ones rejected by AddE are skipped.
+func (o *Octree) AddBatch(entities []siface.ISpatial) int {
+	entities, duplicates := tree.Admissible(entities, o.Contains)
+	root, added := o.root, o.root.AddBatch(entities)
+	for _, entity := range entities {
+		if root.Contains(entity) {
+			o.extent.Grow(entity)
+		} else if o.addOutOfBounds(entity) {
+			o.extent.Grow(entity)
+			added++
+		}
+	}
+	return added + tree.AddEach(o, duplicates)
 }
 
 // Remove removes an entity from the octree by its ID.
@@ -53,7 +113,61 @@ func (o *Octree) Add(entity siface.ISpatial) bool {
 // - entityId: the ID of the entity to be removed.
 // Returns true if the entity was removed successfully, false otherwise.
 func (o *Octree) Remove(entityId int64) bool {
-	return o.root.Remove(entityId, o.option.MergeIf())
+	return o.RemoveE(entityId) == nil
+}
+
+// RemoveE removes an entity from the octree by its ID, returns siface.ErrNotFound if it's not in the octree.
+func (o *Octree) RemoveE(entityId int64) error {
+	if leaf, ok := o.leaves[entityId]; ok && leaf.Remove(entityId, o.option.MergeIf()) {
+		return nil
+	}
+	return tree.NotFound(entityId)
+}
+
+// Get returns the entity with the given ID.
+// Parameters:
+// - entityId: the ID of the entity.
+// Returns the entity and true if it's in the octree, nil and false otherwise.
+func (o *Octree) Get(entityId int64) (siface.ISpatial, bool) {
+	if leaf, ok := o.leaves[entityId]; ok {
+		return leaf.Get(entityId)
+	}
+	return nil, false
+}
+
+// Contains checks if an entity with the given ID is in the octree.
+func (o *Octree) Contains(entityId int64) bool {
+	_, ok := o.leaves[entityId]
+	return ok
+}
+
+// Update relocates an entity in the octree after its location has changed.
+// Parameters:
+// - entity: the spatial entity at its new location.
+// - oldLocation: not needed by the octree, the leaf holding the entity is looked up by ID.
+// Returns true if the entity was updated successfully, false otherwise, see UpdateE.
+func (o *Octree) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	return o.UpdateE(entity, oldLocation) == nil
+}
+
+// UpdateE relocates an entity in the octree after its location has changed, like Update.
+// The entity moving out of the bound of the octree is relocated by the policy, see option.WithOutOfBounds.
+// Returns siface.ErrInvalidBound, siface.ErrNotFound, or siface.ErrOutOfBounds if the out-of-bounds policy rejects it.
+func (o *Octree) UpdateE(entity siface.ISpatial, _ geo.Vec3Int) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	leaf, ok := o.leaves[entity.GetID()]
+	if !ok {
+		return tree.NotFound(entity.GetID())
+	}
+	if !leaf.Relocate(entity, o.option.MergeIf()) {
+		if o.root, ok = tree.RelocateOutOfBounds(o.root, leaf, entity, o.option.OutOfBounds(), o.option.MergeIf()); !ok {
+			return tree.OutOfBounds(entity)
+		}
+	}
+	o.extent.Grow(entity)
+	return nil
 }
 
 // GetSurroundingEntities finds entities within a certain radius of a center point.
@@ -63,7 +177,178 @@ func (o *Octree) Remove(entityId int64) bool {
 // - filters: optional filters to apply to the entities.
 // Returns a slice of spatial entities within the specified radius.
 func (o *Octree) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
-	return o.root.FindEntities(o.option.ScaleFunc(center), radius, filters...)
+	return o.AppendSurroundingEntities(make([]siface.ISpatial, 0), center, radius, filters...)
+}
+
+// AppendSurroundingEntities appends the entities within a certain radius of a center point to dst,
+// and returns the extended slice, like GetSurroundingEntities without allocating a new one.
+func (o *Octree) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	o.visitSurroundingEntities(center, radius, o.option.Metric(), func(entity siface.ISpatial) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters)
+	return dst
+}
+
+// VisitSurroundingEntities calls visit for each entity within a certain radius of a center point,
+// until visit returns false.
+func (o *Octree) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	o.visitSurroundingEntities(center, radius, o.option.Metric(), visit, filters)
+}
+
+// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric.
+// The euclidean distance is used if the metric is nil.
+func (o *Octree) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	o.visitSurroundingEntities(center, radius, metric, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	}, filters)
+	return ret
+}
+
+// visitSurroundingEntities calls visit for each entity within radius of the center measured by the metric, until visit returns false.
+func (o *Octree) visitSurroundingEntities(center []float32, radius float32, metric util.DistanceMetric, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) {
+	var buf [3]int32
+	c := o.option.ScaleTo(buf[:], center)
+	if metric == nil {
+		o.root.VisitEntities(c, radius, visit, filters...)
+		return
+	}
+	mq := tree.NewMetricQuery(metric, c, radius, []int{0, 1, 2})
+	within := func(entity siface.ISpatial) bool {
+		if o.root.Loose() {
+			return mq.WithinBound(tree.BoundOf(entity))
+		}
+		return mq.Within(entity.GetLocation())
+	}
+	o.root.VisitEntitiesWith(mq.Bound(), within, visit, filters...)
+}
+
+// GetEntitiesInBound finds entities whose location(bound if the tree is loose) is within(intersects) a box.
+// Parameters:
+// - bound: the box to search in, boundaries included.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities within the box.
+func (o *Octree) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return o.root.FindEntitiesInBound(bound, filters...)
+}
+
+// GetIntersecting finds entities whose bound intersects a box.
+// Parameters:
+// - bound: the box to test, boundaries included.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities whose bound intersects the box.
+func (o *Octree) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	intersects := func(entity siface.ISpatial) bool {
+		return tree.BoundOf(entity).Intersects(bound)
+	}
+	if o.root.Loose() {
+		// the bounds of the entities are within the loose bounds of their nodes.
+		return o.root.FindEntitiesWith(bound, intersects, filters...)
+	}
+	return o.root.FindEntitiesWith(o.extent.Expand(bound), intersects, filters...)
+}
+
+// GetCollisionPairs finds all pairs of entities whose bounds overlap.
+func (o *Octree) GetCollisionPairs() [][2]siface.ISpatial {
+	return tree.CollisionPairs(o.rangeEntities, func(bound bounds.Bound) []siface.ISpatial {
+		return o.GetIntersecting(bound)
+	})
+}
+
+// GetNearest finds the k entities nearest to a center point.
+// Parameters:
+// - center: the center point to search around.
+// - k: the maximum number of entities to return.
+// - maxDistance: the maximum distance of the entities, no limit if it's not positive.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities sorted by ascending distance.
+func (o *Octree) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return o.root.FindNearest(o.option.ScaleFunc(center), k, maxDistance, filters...)
+}
+
+// All returns an iterator over all the entities in the octree.
+func (o *Octree) All() iter.Seq[siface.ISpatial] {
+	return o.root.All()
+}
+
+// Within returns an iterator over the entities within a certain radius of a center point,
+// the search stops when the loop breaks.
+func (o *Octree) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		o.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+// Nodes returns an iterator over the nodes of the octree in depth-first order.
+func (o *Octree) Nodes() iter.Seq[siface.NodeInfo] {
+	return func(yield func(info siface.NodeInfo) bool) {
+		for n := range o.root.Nodes() {
+			if !yield(siface.NodeInfo{Bound: n.Bound(), Depth: n.Depth(), Count: n.Size()}) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of entities in the octree.
+func (o *Octree) Len() int {
+	return len(o.leaves)
+}
+
+// Stats returns the statistics of the octree.
+func (o *Octree) Stats() siface.Stats {
+	return tree.StatsOf(o.root)
+}
+
+// Snapshot writes the config, the nodes and the entities of the octree to w, see Restore.
+func (o *Octree) Snapshot(w io.Writer) error {
+	e := tree.NewEncoder(w, tree.KindOctree)
+	e.Bound(o.root.Bound())
+	e.Int32(int32(o.root.MaxDepth()))
+	e.Int32(int32(o.root.Capacity()))
+	e.Bool(o.option.MergeIf())
+	e.Float64(o.root.LooseFactor())
+	e.Uint8(uint8(o.option.OutOfBounds()))
+	tree.EncodeNodes(e, o.root)
+	return e.Close()
+}
+
+// Restore restores a octree from a snapshot written by Snapshot, the entities are put back to the nodes they were in.
+// Parameters:
+// - d: the decoder of the snapshot, whose header has been read.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil, and added again if it has moved.
+// - optional: variadic optional parameters to configure the octree, mergeIf, the loose factor and the out-of-bounds policy are restored from the snapshot.
+func Restore(d *tree.Decoder, resolve func(id int64) siface.ISpatial, optional ...option.Optional) (*Octree, error) {
+	bound, maxDepth, capacity, merge := d.Bound(), int(d.Int32()), int(d.Int32()), d.Bool()
+	var loose float64
+	if d.Version() >= 2 {
+		loose = d.Float64()
+	}
+	outside := consts.OutOfBoundsReject
+	if d.Version() >= 3 {
+		outside = consts.OutOfBounds(d.Uint8())
+	}
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	o, err := NewOctree(bound, maxDepth, capacity, append(slices.Clone(optional), option.WithMergeIf(merge), option.WithLoose(loose), option.WithOutOfBounds(outside))...)
+	if err != nil {
+		return nil, err
+	}
+	moved := tree.DecodeNodes(d, o.root, resolve)
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	o.rangeEntities(func(entity siface.ISpatial) bool {
+		o.extent.Grow(entity)
+		return true
+	})
+	for _, entity := range moved {
+		o.Add(entity)
+	}
+	return o, nil
 }
 
 // ToDot generates a dot file for the search tree.
@@ -80,3 +365,12 @@ func (o *Octree) ToDot() error {
 		return tree.ToDot(tree.GetTemplate(), o.root, file)
 	}
 }
+
+// rangeEntities ranges all the entities in the octree.
+func (o *Octree) rangeEntities(f func(entity siface.ISpatial) bool) {
+	for id, leaf := range o.leaves {
+		if entity, ok := leaf.Get(id); ok && !f(entity) {
+			return
+		}
+	}
+}
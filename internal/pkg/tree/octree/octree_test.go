@@ -3,6 +3,7 @@ package octree
 import (
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/siface"
 	"testing"
 
 	"github.com/cozmo-zh/zearches/pkg/bounds"
@@ -74,3 +75,114 @@ func TestOctree_GetSurroundingEntities(t *testing.T) {
 	ret = oct.GetSurroundingEntities([]float32{1, 1, 1}, 1)
 	assert.True(t, len(ret) == 0)
 }
+
+func TestOctree_Update(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 90, 90, 90)
+	oct.Add(entity1)
+	oct.Add(entity2)
+	moved := mocks.CreateMockSpatial(1, 80, 80, 80)
+	assert.True(t, oct.Update(moved, geo.NewVec3Int(10, 10, 10)))
+	assert.Len(t, oct.GetSurroundingEntities([]float32{10, 10, 10}, 1), 0)
+	ret := oct.GetSurroundingEntities([]float32{80, 80, 80}, 1)
+	assert.Len(t, ret, 1)
+	assert.Equal(t, moved, ret[0])
+	assert.False(t, oct.Update(mocks.CreateMockSpatial(3, 1, 1, 1), geo.NewVec3Int(1, 1, 1)))
+}
+
+func TestOctree_GetAndContains(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 4, 1, option.WithMergeIf(true))
+	entities := make([]siface.ISpatial, 0)
+	for i := int32(1); i <= 20; i++ {
+		entity := mocks.CreateMockSpatial(int64(i), i*4, i*4, i*4)
+		entities = append(entities, entity)
+		assert.True(t, oct.Add(entity))
+	}
+	for _, entity := range entities {
+		// the index stays consistent across DivideIf
+		leaf := oct.leaves[entity.GetID()]
+		assert.True(t, leaf.IsLeaf())
+		got, ok := oct.Get(entity.GetID())
+		assert.True(t, ok)
+		assert.Equal(t, entity, got)
+		assert.True(t, oct.Contains(entity.GetID()))
+	}
+	for _, entity := range entities[:15] {
+		assert.True(t, oct.Remove(entity.GetID()))
+		assert.False(t, oct.Contains(entity.GetID()))
+	}
+	for _, entity := range entities[15:] {
+		// and across MergeIf
+		got, ok := oct.Get(entity.GetID())
+		assert.True(t, ok)
+		assert.Equal(t, entity, got)
+	}
+	assert.Len(t, oct.leaves, 5)
+	_, ok := oct.Get(999)
+	assert.False(t, ok)
+	assert.False(t, oct.Remove(999))
+}
+
+func TestOctree_GetNearest(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 20, 20, 20)
+	entity3 := mocks.CreateMockSpatial(3, 90, 90, 90)
+	oct.Add(entity1)
+	oct.Add(entity2)
+	oct.Add(entity3)
+	ret := oct.GetNearest([]float32{25, 25, 25}, 2, 0)
+	assert.Equal(t, []siface.ISpatial{entity2, entity1}, ret)
+	ret = oct.GetNearest([]float32{25, 25, 25}, 3, 10)
+	assert.Equal(t, []siface.ISpatial{entity2}, ret)
+}
+
+func TestOctree_GetEntitiesInBound(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 20, 20, 20)
+	entity3 := mocks.CreateMockSpatial(3, 90, 90, 90)
+	oct.Add(entity1)
+	oct.Add(entity2)
+	oct.Add(entity3)
+	ret := oct.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(10, 10, 10), geo.NewVec3Int(20, 20, 20)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity1, entity2}, ret)
+	ret = oct.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(10, 10, 10), geo.NewVec3Int(20, 20, 19)))
+	assert.Equal(t, []siface.ISpatial{entity1}, ret)
+}
+
+func TestOctree_GetIntersecting(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 3, 1)
+	point := mocks.CreateMockSpatial(1, 10, 10, 10)
+	sized := mocks.CreateMockSpatial(2, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(30, 30, 30), geo.NewVec3Int(70, 70, 70)))
+	far := mocks.CreateMockSpatial(3, 90, 90, 90, bounds.NewBound(geo.NewVec3Int(85, 85, 85), geo.NewVec3Int(95, 95, 95)))
+	oct.Add(point)
+	oct.Add(sized)
+	oct.Add(far)
+	// the box doesn't cover the location of the sized entity, but its bound
+	ret := oct.GetIntersecting(bounds.NewBound(geo.NewVec3Int(5, 5, 5), geo.NewVec3Int(30, 30, 30)))
+	assert.ElementsMatch(t, []siface.ISpatial{point, sized}, ret)
+	ret = oct.GetIntersecting(bounds.NewBound(geo.NewVec3Int(75, 75, 75), geo.NewVec3Int(80, 80, 80)))
+	assert.Empty(t, ret)
+}
+
+func TestOctree_GetCollisionPairs(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	oct, _ := NewOctree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10, bounds.NewBound(geo.NewVec3Int(5, 5, 5), geo.NewVec3Int(15, 15, 15)))
+	entity2 := mocks.CreateMockSpatial(2, 20, 20, 20, bounds.NewBound(geo.NewVec3Int(15, 15, 15), geo.NewVec3Int(25, 25, 25)))
+	entity3 := mocks.CreateMockSpatial(3, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(24, 24, 24), geo.NewVec3Int(80, 80, 80)))
+	entity4 := mocks.CreateMockSpatial(4, 90, 90, 90)
+	oct.Add(entity1)
+	oct.Add(entity2)
+	oct.Add(entity3)
+	oct.Add(entity4)
+	pairs := oct.GetCollisionPairs()
+	assert.ElementsMatch(t, [][2]siface.ISpatial{{entity1, entity2}, {entity2, entity3}}, pairs)
+}
@@ -1,13 +1,22 @@
 // Package option .
 package option
 
-import "github.com/cozmo-zh/zearches/pkg/geo"
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/util"
+)
 
 // OptionalSettings holds configuration options for creating spatial trees.
 type OptionalSettings struct {
 	mergeIf   bool                          // Merge the node when removing an entity. not suggested to set to true
 	scaleFunc func(v []float32) geo.Vec3Int // Scale the float32 slice to Vec3Int
 	path      string                        // the path to draw the tree
+	plane     consts.Plane                  // the plane of a 2D tree
+	metric    util.DistanceMetric           // the metric of the surrounding queries, nil for the metric of the dimension
+	loose     float64                       // the loose factor of a tree, 0 if it's not loose
+	outside   consts.OutOfBounds            // the policy of an octree or a quadtree for the entities out of its bound
+	duplicate consts.Duplicate              // the policy for adding an entity whose ID is in the tree
 }
 
 // Optional is a function type used to configure optional parameters for the Octree.
@@ -17,9 +26,6 @@ type Optional func(o *OptionalSettings)
 func OptionalDefault() *OptionalSettings {
 	return &OptionalSettings{
 		mergeIf: false,
-		scaleFunc: func(v []float32) geo.Vec3Int {
-			return geo.NewVec3Int(int32(v[0]), int32(v[1]), int32(v[2]))
-		},
 	}
 }
 
@@ -48,12 +54,47 @@ func WithDrawPath(path string) Optional {
 	}
 }
 
+// WithPlane sets the plane of a 2D tree, x/z by default.
+func WithPlane(plane consts.Plane) Optional {
+	return func(o *OptionalSettings) {
+		o.plane = plane
+	}
+}
+
+// WithMetric sets the distance metric of the surrounding queries.
+func WithMetric(metric util.DistanceMetric) Optional {
+	return func(o *OptionalSettings) {
+		o.metric = metric
+	}
+}
+
+// WithLoose sets the loose factor of an octree or a quadtree, the entities are indexed by their bounds if it's set.
+func WithLoose(k float64) Optional {
+	return func(o *OptionalSettings) {
+		o.loose = k
+	}
+}
+
+// WithOutOfBounds sets the policy of an octree or a quadtree for the entities out of its bound, rejected by default.
+func WithOutOfBounds(policy consts.OutOfBounds) Optional {
+	return func(o *OptionalSettings) {
+		o.outside = policy
+	}
+}
+
+// WithDuplicate sets the policy for adding an entity whose ID is in the tree, rejected by default.
+func WithDuplicate(policy consts.Duplicate) Optional {
+	return func(o *OptionalSettings) {
+		o.duplicate = policy
+	}
+}
+
 // MergeIf returns the mergeIf field of the Octree.
 func (o *OptionalSettings) MergeIf() bool {
 	return o.mergeIf
 }
 
-// ScaleFunc returns the scaleFunc field of the Octree.
+// ScaleFunc scales the float32 slice by the scaleFunc field of the Octree, the elements are truncated to int32 if it's not set.
 func (o *OptionalSettings) ScaleFunc(v []float32) geo.Vec3Int {
 	if o.scaleFunc == nil {
 		return geo.NewVec3Int(int32(v[0]), int32(v[1]), int32(v[2]))
@@ -61,7 +102,47 @@ func (o *OptionalSettings) ScaleFunc(v []float32) geo.Vec3Int {
 	return o.scaleFunc(v)
 }
 
+// HasScale checks if the scaleFunc field is set, for the trees which keep the precision of the float32 slices otherwise.
+func (o *OptionalSettings) HasScale() bool {
+	return o.scaleFunc != nil
+}
+
+// ScaleTo is ScaleFunc writing into dst instead of allocating, if the scaleFunc field is not set.
+// dst should have a length of 3, the result is dst or the result of the scaleFunc.
+func (o *OptionalSettings) ScaleTo(dst geo.Vec3Int, v []float32) geo.Vec3Int {
+	if o.scaleFunc == nil {
+		dst[0], dst[1], dst[2] = int32(v[0]), int32(v[1]), int32(v[2])
+		return dst
+	}
+	return o.scaleFunc(v)
+}
+
 // DrawPath returns the path to draw the tree.
 func (o *OptionalSettings) DrawPath() string {
 	return o.path
 }
+
+// Plane returns the plane of a 2D tree.
+func (o *OptionalSettings) Plane() consts.Plane {
+	return o.plane
+}
+
+// Metric returns the distance metric of the surrounding queries, nil for the metric of the dimension.
+func (o *OptionalSettings) Metric() util.DistanceMetric {
+	return o.metric
+}
+
+// Loose returns the loose factor of a tree, 0 if it's not loose.
+func (o *OptionalSettings) Loose() float64 {
+	return o.loose
+}
+
+// OutOfBounds returns the policy of an octree or a quadtree for the entities out of its bound.
+func (o *OptionalSettings) OutOfBounds() consts.OutOfBounds {
+	return o.outside
+}
+
+// Duplicate returns the policy for adding an entity whose ID is in the tree.
+func (o *OptionalSettings) Duplicate() consts.Duplicate {
+	return o.duplicate
+}
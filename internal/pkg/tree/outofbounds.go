@@ -0,0 +1,63 @@
+// Package tree .
+package tree
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/treenode"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// AddOutOfBounds adds the entity out of the bound of the tree of the root by the policy, see consts.OutOfBounds.
+//
+// Parameters:
+// - root: the root of an octree or a quadtree.
+// - entity: the spatial entity which the root doesn't contain.
+// - policy: the policy for the entities out of the bound.
+//
+// Returns the root, a new one if the tree has grown, and false if the entity is rejected.
+func AddOutOfBounds(root *treenode.TreeNode, entity siface.ISpatial, policy consts.OutOfBounds) (*treenode.TreeNode, bool) {
+	switch policy {
+	case consts.OutOfBoundsExpand:
+		root, ok := growToFit(root, entity)
+		return root, ok && root.Add(entity)
+	case consts.OutOfBoundsClamp:
+		root.PutNearest(entity)
+		return root, true
+	case consts.OutOfBoundsOverflow:
+		root.Put(entity)
+		return root, true
+	default:
+		return root, false
+	}
+}
+
+// RelocateOutOfBounds relocates the entity held by the leaf, which has moved out of the bound of the tree of the root,
+// by the policy, see consts.OutOfBounds.
+//
+// Returns the root, a new one if the tree has grown, and false if the entity is rejected, it stays in the leaf then.
+func RelocateOutOfBounds(root, leaf *treenode.TreeNode, entity siface.ISpatial, policy consts.OutOfBounds, merge bool) (*treenode.TreeNode, bool) {
+	switch policy {
+	case consts.OutOfBoundsExpand:
+		root, ok := growToFit(root, entity)
+		return root, ok && leaf.Relocate(entity, merge)
+	case consts.OutOfBoundsClamp, consts.OutOfBoundsOverflow:
+		leaf.Remove(entity.GetID(), merge)
+		return AddOutOfBounds(root, entity, policy)
+	default:
+		return root, false
+	}
+}
+
+// growToFit grows the tree of the root until it contains the entity.
+// Returns the root, a new one if the tree has grown, and false if it can't grow enough,
+// the tree keeps the bound it has grown to.
+func growToFit(root *treenode.TreeNode, entity siface.ISpatial) (*treenode.TreeNode, bool) {
+	for !root.Contains(entity) {
+		grown, err := root.Grow(entity.GetLocation())
+		if err != nil {
+			return root, false
+		}
+		root = grown
+	}
+	return root, true
+}
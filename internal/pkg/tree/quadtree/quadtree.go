@@ -8,14 +8,24 @@ import (
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/treenode"
 	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
 	"os"
 	"path"
+	"slices"
 )
 
 // QuadTree represents a quadtree data structure.
+//
+// A loose quadtree(see option.WithLoose) indexes the entities by their bounds, the queries measure their bounds instead of their locations.
+// The entities out of the bound are rejected, unless another policy is set by option.WithOutOfBounds, like growing the quadtree.
 type QuadTree struct {
-	root   *treenode.TreeNode // The root node of the quadtree.
+	root   *treenode.TreeNode           // The root node of the quadtree.
+	leaves map[int64]*treenode.TreeNode // Map of entity IDs to the leaf holding them.
+	extent tree.Extent                  // How far the bounds of the entities reach beyond their locations.
 	option *option.OptionalSettings
 }
 
@@ -31,11 +41,17 @@ func NewQuadtree(bound bounds.Bound, maxDepth int, capacity int, optional ...opt
 	} else {
 		o := &QuadTree{
 			root:   root,
+			leaves: make(map[int64]*treenode.TreeNode),
 			option: option.OptionalDefault(),
 		}
+		root.SetLeafIndex(o.leaves)
 		for _, opt := range optional {
 			opt(o.option)
 		}
+		root.SetPlane(o.option.Plane())
+		if err = root.SetLoose(o.option.Loose()); err != nil {
+			return nil, err
+		}
 		return o, nil
 	}
 }
@@ -43,9 +59,54 @@ func NewQuadtree(bound bounds.Bound, maxDepth int, capacity int, optional ...opt
 // Add adds an entity to the quadtree.
 // Parameters:
 // - entity: the spatial entity to be added.
-// Returns true if the entity was added successfully, false otherwise.
+// Returns true if the entity was added successfully, false otherwise, see AddE.
 func (q *QuadTree) Add(entity siface.ISpatial) bool {
-	return q.root.Add(entity)
+	return q.AddE(entity) == nil
+}
+
+// AddE adds an entity to the quadtree.
+// The entity out of the bound of the quadtree is added by the policy, see option.WithOutOfBounds.
+// Parameters:
+// - entity: the spatial entity to be added.
+// Returns siface.ErrInvalidBound, siface.ErrDuplicateID if the duplicate policy rejects it, or siface.ErrOutOfBounds if the out-of-bounds policy rejects it.
+func (q *QuadTree) AddE(entity siface.ISpatial) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	if q.Contains(entity.GetID()) {
+		return tree.AddDuplicate(q, entity, q.option.Duplicate())
+	}
+	if !q.root.Add(entity) && !q.addOutOfBounds(entity) {
+		return tree.OutOfBounds(entity)
+	}
+	q.extent.Grow(entity)
+	return nil
+}
+
+// addOutOfBounds adds the entity out of the bound of the quadtree by the policy, the root is replaced if the quadtree grows.
+func (q *QuadTree) addOutOfBounds(entity siface.ISpatial) bool {
+	root, ok := tree.AddOutOfBounds(q.root, entity, q.option.OutOfBounds())
+	q.root = root
+	return ok
+}
+
+// AddBatch adds the entities to the quadtree top-down in one pass, faster than adding them one by one.
+// Parameters:
+// - entities: the spatial entities to be added, the ones out of the bounds of the quadtree are added one by one by the policy.
+// Returns the number of entities added, the ones whose ID is in the tree or repeated in the batch are added one by one
+// by the duplicate policy, see option.WithDuplicate, and the ones rejected by AddE are skipped.
+func (q *QuadTree) AddBatch(entities []siface.ISpatial) int {
+	entities, duplicates := tree.Admissible(entities, q.Contains)
+	root, added := q.root, q.root.AddBatch(entities)
+	for _, entity := range entities {
+		if root.Contains(entity) {
+			q.extent.Grow(entity)
+		} else if q.addOutOfBounds(entity) {
+			q.extent.Grow(entity)
+			added++
+		}
+	}
+	return added + tree.AddEach(q, duplicates)
 }
 
 // Remove removes an entity from the quadtree by its ID.
@@ -53,19 +114,246 @@ func (q *QuadTree) Add(entity siface.ISpatial) bool {
 // - entityId: the ID of the entity to be removed.
 // Returns true if the entity was removed successfully, false otherwise.
 func (q *QuadTree) Remove(entityId int64) bool {
-	return q.root.Remove(entityId, q.option.MergeIf())
+	return q.RemoveE(entityId) == nil
+}
+
+// RemoveE removes an entity from the quadtree by its ID, returns siface.ErrNotFound if it's not in the quadtree.
+func (q *QuadTree) RemoveE(entityId int64) error {
+	if leaf, ok := q.leaves[entityId]; ok && leaf.Remove(entityId, q.option.MergeIf()) {
+		return nil
+	}
+	return tree.NotFound(entityId)
+}
+
+// Get returns the entity with the given ID.
+// Parameters:
+// - entityId: the ID of the entity.
+// Returns the entity and true if it's in the quadtree, nil and false otherwise.
+func (q *QuadTree) Get(entityId int64) (siface.ISpatial, bool) {
+	if leaf, ok := q.leaves[entityId]; ok {
+		return leaf.Get(entityId)
+	}
+	return nil, false
 }
 
-// GetSurroundingEntities finds entities within a certain radius of a center point.
+// Contains checks if an entity with the given ID is in the quadtree.
+func (q *QuadTree) Contains(entityId int64) bool {
+	_, ok := q.leaves[entityId]
+	return ok
+}
+
+// Update relocates an entity in the quadtree after its location has changed.
+// Parameters:
+// - entity: the spatial entity at its new location.
+// - oldLocation: not needed by the quadtree, the leaf holding the entity is looked up by ID.
+// Returns true if the entity was updated successfully, false otherwise, see UpdateE.
+func (q *QuadTree) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	return q.UpdateE(entity, oldLocation) == nil
+}
+
+// UpdateE relocates an entity in the quadtree after its location has changed, like Update.
+// The entity moving out of the bound of the quadtree is relocated by the policy, see option.WithOutOfBounds.
+// Returns siface.ErrInvalidBound, siface.ErrNotFound, or siface.ErrOutOfBounds if the out-of-bounds policy rejects it.
+func (q *QuadTree) UpdateE(entity siface.ISpatial, _ geo.Vec3Int) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	leaf, ok := q.leaves[entity.GetID()]
+	if !ok {
+		return tree.NotFound(entity.GetID())
+	}
+	if !leaf.Relocate(entity, q.option.MergeIf()) {
+		if q.root, ok = tree.RelocateOutOfBounds(q.root, leaf, entity, q.option.OutOfBounds(), q.option.MergeIf()); !ok {
+			return tree.OutOfBounds(entity)
+		}
+	}
+	q.extent.Grow(entity)
+	return nil
+}
+
+// GetSurroundingEntities finds entities within a certain radius of a center point, measured on the plane of the quadtree.
 // Parameters:
 // - center: the center point to search around.
 // - radius: the radius within which to search for entities.
 // - filters: optional filters to apply to the entities.
 // Returns a slice of spatial entities within the specified radius.
 func (q *QuadTree) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
-	return q.root.FindEntities(q.option.ScaleFunc(center), radius, filters...)
+	return q.AppendSurroundingEntities(make([]siface.ISpatial, 0), center, radius, filters...)
+}
+
+// AppendSurroundingEntities appends the entities within a certain radius of a center point to dst,
+// and returns the extended slice, like GetSurroundingEntities without allocating a new one.
+func (q *QuadTree) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	q.visitSurroundingEntities(center, radius, q.option.Metric(), func(entity siface.ISpatial) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters)
+	return dst
+}
+
+// VisitSurroundingEntities calls visit for each entity within a certain radius of a center point,
+// until visit returns false.
+func (q *QuadTree) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	q.visitSurroundingEntities(center, radius, q.option.Metric(), visit, filters)
+}
+
+// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric on the plane of the quadtree.
+// The euclidean distance is used if the metric is nil.
+func (q *QuadTree) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	q.visitSurroundingEntities(center, radius, metric, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	}, filters)
+	return ret
+}
+
+// visitSurroundingEntities calls visit for each entity within radius of the center measured by the metric, until visit returns false.
+func (q *QuadTree) visitSurroundingEntities(center []float32, radius float32, metric util.DistanceMetric, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) {
+	var buf [3]int32
+	c := q.option.ScaleTo(buf[:], center)
+	if metric == nil {
+		q.root.VisitEntities(c, radius, visit, filters...)
+		return
+	}
+	mq := tree.NewMetricQuery(metric, c, radius, q.option.Plane().Axes())
+	within := func(entity siface.ISpatial) bool {
+		if q.root.Loose() {
+			return mq.WithinBound(tree.BoundOf(entity))
+		}
+		return mq.Within(entity.GetLocation())
+	}
+	q.root.VisitEntitiesWith(mq.Bound(), within, visit, filters...)
 }
 
+// GetEntitiesInBound finds entities whose location(bound if the tree is loose) is within(intersects) a box on the plane of the quadtree.
+// Parameters:
+// - bound: the box to search in, boundaries included.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities within the box.
+func (q *QuadTree) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return q.root.FindEntitiesInBound(bound, filters...)
+}
+
+// GetIntersecting finds entities whose bound intersects a box on the plane of the quadtree.
+// Parameters:
+// - bound: the box to test, boundaries included.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities whose bound intersects the box.
+func (q *QuadTree) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	intersects := func(entity siface.ISpatial) bool {
+		return tree.BoundOf(entity).IntersectsAxes(bound, q.option.Plane().Axes()...)
+	}
+	if q.root.Loose() {
+		// the bounds of the entities are within the loose bounds of their nodes.
+		return q.root.FindEntitiesWith(bound, intersects, filters...)
+	}
+	return q.root.FindEntitiesWith(q.extent.Expand(bound), intersects, filters...)
+}
+
+// GetCollisionPairs finds all pairs of entities whose bounds overlap on the plane of the quadtree.
+func (q *QuadTree) GetCollisionPairs() [][2]siface.ISpatial {
+	return tree.CollisionPairs(q.rangeEntities, func(bound bounds.Bound) []siface.ISpatial {
+		return q.GetIntersecting(bound)
+	})
+}
+
+// GetNearest finds the k entities nearest to a center point.
+// Parameters:
+// - center: the center point to search around.
+// - k: the maximum number of entities to return.
+// - maxDistance: the maximum distance of the entities, no limit if it's not positive.
+// - filters: optional filters to apply to the entities.
+// Returns a slice of spatial entities sorted by ascending distance on the plane of the quadtree.
+func (q *QuadTree) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return q.root.FindNearest(q.option.ScaleFunc(center), k, maxDistance, filters...)
+}
+
+// All returns an iterator over all the entities in the quadtree.
+func (q *QuadTree) All() iter.Seq[siface.ISpatial] {
+	return q.root.All()
+}
+
+// Within returns an iterator over the entities within a certain radius of a center point,
+// the search stops when the loop breaks.
+func (q *QuadTree) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		q.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+// Nodes returns an iterator over the nodes of the quadtree in depth-first order.
+func (q *QuadTree) Nodes() iter.Seq[siface.NodeInfo] {
+	return func(yield func(info siface.NodeInfo) bool) {
+		for n := range q.root.Nodes() {
+			if !yield(siface.NodeInfo{Bound: n.Bound(), Depth: n.Depth(), Count: n.Size()}) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of entities in the quadtree.
+func (q *QuadTree) Len() int {
+	return len(q.leaves)
+}
+
+// Stats returns the statistics of the quadtree.
+func (q *QuadTree) Stats() siface.Stats {
+	return tree.StatsOf(q.root)
+}
+
+// Snapshot writes the config, the nodes and the entities of the quadtree to w, see Restore.
+func (q *QuadTree) Snapshot(w io.Writer) error {
+	e := tree.NewEncoder(w, tree.KindQuadtree)
+	e.Bound(q.root.Bound())
+	e.Int32(int32(q.root.MaxDepth()))
+	e.Int32(int32(q.root.Capacity()))
+	e.Bool(q.option.MergeIf())
+	e.Uint8(uint8(q.option.Plane()))
+	e.Float64(q.root.LooseFactor())
+	e.Uint8(uint8(q.option.OutOfBounds()))
+	tree.EncodeNodes(e, q.root)
+	return e.Close()
+}
+
+// Restore restores a quadtree from a snapshot written by Snapshot, the entities are put back to the nodes they were in.
+// Parameters:
+// - d: the decoder of the snapshot, whose header has been read.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil, and added again if it has moved.
+// - optional: variadic optional parameters to configure the quadtree, mergeIf, the plane, the loose factor and the out-of-bounds policy are restored from the snapshot.
+func Restore(d *tree.Decoder, resolve func(id int64) siface.ISpatial, optional ...option.Optional) (*QuadTree, error) {
+	bound, maxDepth, capacity, merge, plane := d.Bound(), int(d.Int32()), int(d.Int32()), d.Bool(), consts.Plane(d.Uint8())
+	var loose float64
+	if d.Version() >= 2 {
+		loose = d.Float64()
+	}
+	outside := consts.OutOfBoundsReject
+	if d.Version() >= 3 {
+		outside = consts.OutOfBounds(d.Uint8())
+	}
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	q, err := NewQuadtree(bound, maxDepth, capacity, append(slices.Clone(optional), option.WithMergeIf(merge), option.WithPlane(plane), option.WithLoose(loose), option.WithOutOfBounds(outside))...)
+	if err != nil {
+		return nil, err
+	}
+	moved := tree.DecodeNodes(d, q.root, resolve)
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	q.rangeEntities(func(entity siface.ISpatial) bool {
+		q.extent.Grow(entity)
+		return true
+	})
+	for _, entity := range moved {
+		q.Add(entity)
+	}
+	return q, nil
+}
+
+// ToDot generates a dot file for the search tree.
 func (q *QuadTree) ToDot() error {
 	const fileName = "quadtree.dot"
 	if q.option.DrawPath() == "" {
@@ -79,3 +367,12 @@ func (q *QuadTree) ToDot() error {
 		return tree.ToDot(tree.GetTemplate(), q.root, file)
 	}
 }
+
+// rangeEntities ranges all the entities in the quadtree.
+func (q *QuadTree) rangeEntities(f func(entity siface.ISpatial) bool) {
+	for id, leaf := range q.leaves {
+		if entity, ok := leaf.Get(id); ok && !f(entity) {
+			return
+		}
+	}
+}
@@ -2,10 +2,13 @@
 package quadtree
 
 import (
+	"github.com/cozmo-zh/zearches/consts"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
 	"github.com/cozmo-zh/zearches/pkg/bounds"
 	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -52,3 +55,126 @@ func TestQuadTree_GetSurroundingEntities(t *testing.T) {
 	assert.Len(t, entities, 1)
 	assert.Equal(t, entity1, entities[0])
 }
+
+func TestQuadTree_Update(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 90, 10, 90)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	moved := mocks.CreateMockSpatial(1, 80, 10, 80)
+	assert.True(t, quad.Update(moved, geo.NewVec3Int(10, 10, 10)))
+	assert.Len(t, quad.GetSurroundingEntities([]float32{10, 10, 10}, 1), 0)
+	ret := quad.GetSurroundingEntities([]float32{80, 10, 80}, 1)
+	assert.Len(t, ret, 1)
+	assert.Equal(t, moved, ret[0])
+}
+
+func TestQuadTree_GetAndContains(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 90, 10, 90)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	got, ok := quad.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, entity2, got)
+	assert.True(t, quad.Contains(1))
+	quad.Remove(1)
+	assert.False(t, quad.Contains(1))
+	_, ok = quad.Get(1)
+	assert.False(t, ok)
+}
+
+func TestQuadTree_GetNearest(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 20, 10, 20)
+	entity3 := mocks.CreateMockSpatial(3, 90, 10, 90)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	quad.Add(entity3)
+	ret := quad.GetNearest([]float32{85, 10, 85}, 2, 0)
+	assert.Equal(t, []siface.ISpatial{entity3, entity2}, ret)
+}
+
+func TestQuadTree_GetEntitiesInBound(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 50, 10)
+	entity2 := mocks.CreateMockSpatial(2, 20, 0, 20)
+	entity3 := mocks.CreateMockSpatial(3, 90, 0, 90)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	quad.Add(entity3)
+	// y is ignored
+	ret := quad.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(30, 0, 30)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity1, entity2}, ret)
+}
+
+func TestQuadTree_GetCollisionPairs(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 0, 10, bounds.NewBound(geo.NewVec3Int(5, 0, 5), geo.NewVec3Int(15, 0, 15)))
+	// different altitude, y is ignored
+	entity2 := mocks.CreateMockSpatial(2, 20, 50, 20, bounds.NewBound(geo.NewVec3Int(15, 50, 15), geo.NewVec3Int(25, 50, 25)))
+	entity3 := mocks.CreateMockSpatial(3, 90, 0, 90)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	quad.Add(entity3)
+	assert.Equal(t, [][2]siface.ISpatial{{entity1, entity2}}, quad.GetCollisionPairs())
+	ret := quad.GetIntersecting(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(5, 0, 5)))
+	assert.Equal(t, []siface.ISpatial{entity1}, ret)
+}
+
+func TestQuadTree_GetSurroundingEntitiesIgnoresAltitude(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1)
+	entity1 := mocks.CreateMockSpatial(1, 10, 90, 10)
+	entity2 := mocks.CreateMockSpatial(2, 15, 0, 10)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	ret := quad.GetSurroundingEntities([]float32{10, 0, 10}, 5)
+	assert.ElementsMatch(t, []siface.ISpatial{entity1, entity2}, ret)
+	ret = quad.GetNearest([]float32{11, 0, 10}, 1, 0)
+	assert.Equal(t, []siface.ISpatial{entity1}, ret)
+}
+
+func TestQuadTree_PlaneXY(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1, option.WithPlane(consts.PlaneXY))
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 90)
+	entity2 := mocks.CreateMockSpatial(2, 10, 90, 10)
+	entity3 := mocks.CreateMockSpatial(3, 90, 90, 0)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	quad.Add(entity3)
+	// z is ignored
+	ret := quad.GetSurroundingEntities([]float32{10, 10, 0}, 5)
+	assert.Equal(t, []siface.ISpatial{entity1}, ret)
+	ret = quad.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 50, 0), geo.NewVec3Int(100, 100, 0)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity2, entity3}, ret)
+	ret = quad.GetNearest([]float32{80, 80, 50}, 1, 0)
+	assert.Equal(t, []siface.ISpatial{entity3}, ret)
+}
+
+func TestQuadTree_PlaneXYMetric(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quad, _ := NewQuadtree(bound, 3, 1, option.WithPlane(consts.PlaneXY))
+	entity1 := mocks.CreateMockSpatial(1, 10, 13, 90)
+	entity2 := mocks.CreateMockSpatial(2, 10, 90, 10)
+	entity3 := mocks.CreateMockSpatial(3, 14, 14, 0)
+	quad.Add(entity1)
+	quad.Add(entity2)
+	quad.Add(entity3)
+	// the metrics measure x and y on the plane, z is ignored like y on the x/z plane.
+	for _, metric := range []util.DistanceMetric{util.Euclidean2D{}, util.Cylinder{HalfHeight: 1}} {
+		ret := quad.GetSurroundingEntitiesWithMetric([]float32{10, 10, 0}, 5, metric)
+		assert.Equal(t, []siface.ISpatial{entity1}, ret)
+	}
+	ret := quad.GetSurroundingEntitiesWithMetric([]float32{10, 10, 0}, 5, util.Chebyshev{})
+	assert.ElementsMatch(t, []siface.ISpatial{entity1, entity3}, ret)
+}
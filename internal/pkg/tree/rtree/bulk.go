@@ -0,0 +1,54 @@
+// Package rtree .
+package rtree
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// load replaces the tree with the entries of the items packed by sort-tile-recursive(STR),
+// the nodes are built bottom-up, nearly full and with little overlap.
+func (r *RTree) load(entries []entry) {
+	height := 0
+	for len(entries) > r.max {
+		groups := r.tile(entries, 0, nil)
+		parents := make([]entry, 0, len(groups))
+		for _, group := range groups {
+			n := r.newNode(height)
+			for _, e := range group {
+				n.add(e)
+			}
+			parents = append(parents, entry{rect: n.bound(r.dims), child: n})
+		}
+		entries = parents
+		height++
+	}
+	r.root = r.newNode(height)
+	for _, e := range entries {
+		r.root.add(e)
+	}
+}
+
+// tile sorts the entries by their centers along the axis and cuts them into slabs, which are tiled along the next axes,
+// the slabs along the last axis are cut into the groups of the nodes, each of at most max entries.
+func (r *RTree) tile(entries []entry, axis int, groups [][]entry) [][]entry {
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.rect.center(axis), b.rect.center(axis))
+	})
+	nodes := (len(entries) + r.max - 1) / r.max
+	if axis == r.dims-1 {
+		// even groups, so that none is much smaller than the others.
+		for i := 0; i < nodes; i++ {
+			groups = append(groups, entries[i*len(entries)/nodes:(i+1)*len(entries)/nodes])
+		}
+		return groups
+	}
+	slabs := int(math.Ceil(math.Pow(float64(nodes), 1/float64(r.dims-axis))))
+	for i := 0; i < slabs; i++ {
+		if from, to := i*len(entries)/slabs, (i+1)*len(entries)/slabs; from < to {
+			groups = r.tile(entries[from:to], axis+1, groups)
+		}
+	}
+	return groups
+}
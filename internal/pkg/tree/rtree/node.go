@@ -0,0 +1,309 @@
+// Package rtree .
+package rtree
+
+import (
+	"cmp"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"math"
+	"slices"
+)
+
+// reinsertFactor is the share of the entries of an overflowing node reinserted before splitting it, 30% as in the R*-tree paper.
+const reinsertFactor = 0.3
+
+// node is a node of the rtree, a leaf holds the items and an internal node holds the child nodes.
+type node struct {
+	parent  *node
+	height  int // 0 for a leaf, the height of the children plus 1 otherwise.
+	entries []entry
+}
+
+// entry is the rect of a child node or of an item.
+type entry struct {
+	rect  rect
+	child *node // the child of an internal node.
+	item  *item // the item of a leaf.
+}
+
+// item is an entity held by a leaf.
+type item struct {
+	entity siface.ISpatial
+	leaf   *node
+}
+
+// leaf checks if the node holds items.
+func (n *node) leaf() bool {
+	return n.height == 0
+}
+
+// bound returns the rect enclosing the entries of the node.
+func (n *node) bound(dims int) rect {
+	if len(n.entries) == 0 {
+		return rect{}
+	}
+	r := n.entries[0].rect
+	for _, e := range n.entries[1:] {
+		r = r.union(e.rect, dims)
+	}
+	return r
+}
+
+// add appends the entry to the node, and links the child or the item back to the node.
+func (n *node) add(e entry) {
+	if e.child != nil {
+		e.child.parent = n
+	} else {
+		e.item.leaf = n
+	}
+	n.entries = append(n.entries, e)
+}
+
+// removeAt removes the entry at i, the order of the entries is not kept.
+func (n *node) removeAt(i int) {
+	last := len(n.entries) - 1
+	n.entries[i] = n.entries[last]
+	n.entries[last] = entry{}
+	n.entries = n.entries[:last]
+}
+
+// indexOf returns the index of the entry of the child, -1 if it's not a child of the node.
+func (n *node) indexOf(child *node) int {
+	for i := range n.entries {
+		if n.entries[i].child == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOfItem returns the index of the entry of the item, -1 if it's not in the node.
+func (n *node) indexOfItem(it *item) int {
+	for i := range n.entries {
+		if n.entries[i].item == it {
+			return i
+		}
+	}
+	return -1
+}
+
+// newNode creates an empty node of the height, with room for an overflowing entry.
+func (r *RTree) newNode(height int) *node {
+	return &node{height: height, entries: make([]entry, 0, r.max+1)}
+}
+
+// rectOfNode returns the rect of the node as recorded by its parent, the bound of its entries for the root.
+func (r *RTree) rectOfNode(n *node) rect {
+	if n.parent == nil {
+		return n.bound(r.dims)
+	}
+	return n.parent.entries[n.parent.indexOf(n)].rect
+}
+
+// insert inserts the entry into a node of the height, the items are inserted at height 0.
+// reinserted records the heights where the entries have been reinserted during the insertion of an item,
+// an overflowing node is split instead of reinserting again at the same height.
+func (r *RTree) insert(e entry, height int, reinserted *uint64) {
+	n := r.chooseNode(e.rect, height)
+	n.add(e)
+	r.enlarge(n, e.rect)
+	for n != nil && len(n.entries) > r.max {
+		n = r.overflow(n, reinserted)
+	}
+}
+
+// chooseNode descends from the root to the node of the height where the rect fits best:
+// the least overlap enlargement above the leaves, the least area enlargement above the other nodes,
+// the ties are broken by the least area.
+func (r *RTree) chooseNode(rc rect, height int) *node {
+	n := r.root
+	for n.height > height {
+		best, bestOverlap, bestEnlargement, bestArea := -1, math.Inf(1), math.Inf(1), math.Inf(1)
+		for i := range n.entries {
+			e := &n.entries[i]
+			area := e.rect.area(r.dims)
+			if e.rect.contains(rc, r.dims) {
+				// neither the area nor the overlap grows.
+				if bestEnlargement > 0 || area < bestArea {
+					best, bestOverlap, bestEnlargement, bestArea = i, 0, 0, area
+				}
+				continue
+			}
+			if bestEnlargement == 0 {
+				continue
+			}
+			union := e.rect.union(rc, r.dims)
+			enlargement := union.area(r.dims) - area
+			overlap := 0.0
+			if n.height == 1 {
+				for j := range n.entries {
+					if j != i && overlap <= bestOverlap {
+						overlap += union.overlap(n.entries[j].rect, r.dims) - e.rect.overlap(n.entries[j].rect, r.dims)
+					}
+				}
+			}
+			if overlap < bestOverlap ||
+				overlap == bestOverlap && (enlargement < bestEnlargement || enlargement == bestEnlargement && area < bestArea) {
+				best, bestOverlap, bestEnlargement, bestArea = i, overlap, enlargement, area
+			}
+		}
+		n = n.entries[best].child
+	}
+	return n
+}
+
+// enlarge grows the rects recorded by the ancestors of the node to enclose the rect.
+func (r *RTree) enlarge(n *node, rc rect) {
+	for p := n.parent; p != nil; n, p = p, p.parent {
+		e := &p.entries[p.indexOf(n)]
+		if e.rect.contains(rc, r.dims) {
+			return
+		}
+		e.rect = e.rect.union(rc, r.dims)
+	}
+}
+
+// tighten recomputes the rects recorded by the ancestors of the node after its entries changed.
+func (r *RTree) tighten(n *node) {
+	for p := n.parent; p != nil; n, p = p, p.parent {
+		p.entries[p.indexOf(n)].rect = n.bound(r.dims)
+	}
+}
+
+// overflow treats the node holding more than max entries, by reinserting some of its entries
+// the first time at its height, or by splitting it. Returns the parent if it may overflow in turn.
+func (r *RTree) overflow(n *node, reinserted *uint64) *node {
+	if n != r.root && *reinserted&(1<<n.height) == 0 {
+		*reinserted |= 1 << n.height
+		r.reinsert(n, reinserted)
+		return nil
+	}
+	sibling := r.split(n)
+	if n == r.root {
+		root := r.newNode(n.height + 1)
+		root.add(entry{rect: n.bound(r.dims), child: n})
+		root.add(entry{rect: sibling.bound(r.dims), child: sibling})
+		r.root = root
+		return nil
+	}
+	p := n.parent
+	p.entries[p.indexOf(n)].rect = n.bound(r.dims)
+	p.add(entry{rect: sibling.bound(r.dims), child: sibling})
+	return p
+}
+
+// reinsert removes the entries farthest from the center of the node and inserts them again from the closest,
+// which lets the tree adapt to the entries inserted since the node was created.
+func (r *RTree) reinsert(n *node, reinserted *uint64) {
+	center := n.bound(r.dims)
+	slices.SortFunc(n.entries, func(a, b entry) int {
+		// descending distance.
+		return cmp.Compare(b.rect.centerDistanceSquared(center, r.dims), a.rect.centerDistanceSquared(center, r.dims))
+	})
+	count := max(1, int(float64(r.max)*reinsertFactor))
+	removed := slices.Clone(n.entries[:count])
+	n.entries = append(n.entries[:0], n.entries[count:]...)
+	r.tighten(n)
+	for i := len(removed) - 1; i >= 0; i-- {
+		r.insert(removed[i], n.height, reinserted)
+	}
+}
+
+// split moves about half of the entries of the node to a new sibling and returns it.
+// the axis is chosen by the least sum of the margins of the distributions,
+// then the distribution by the least overlap, and the least area for the ties.
+func (r *RTree) split(n *node) *node {
+	entries := n.entries
+	count := len(entries)
+	lows := make([]rect, count)
+	highs := make([]rect, count)
+	// distributions computes the prefix and suffix bounds of the sorted entries.
+	distributions := func() {
+		lows[0], highs[count-1] = entries[0].rect, entries[count-1].rect
+		for i := 1; i < count; i++ {
+			lows[i] = lows[i-1].union(entries[i].rect, r.dims)
+			highs[count-1-i] = highs[count-i].union(entries[count-1-i].rect, r.dims)
+		}
+	}
+	sortBy := func(axis int, byMax bool) {
+		slices.SortFunc(entries, func(a, b entry) int {
+			ka, kb, ta, tb := a.rect.min[axis], b.rect.min[axis], a.rect.max[axis], b.rect.max[axis]
+			if byMax {
+				ka, kb, ta, tb = ta, tb, ka, kb
+			}
+			if c := cmp.Compare(ka, kb); c != 0 {
+				return c
+			}
+			return cmp.Compare(ta, tb)
+		})
+	}
+	bestAxis, bestMargin := 0, math.Inf(1)
+	for axis := 0; axis < r.dims; axis++ {
+		margin := 0.0
+		for _, byMax := range []bool{false, true} {
+			sortBy(axis, byMax)
+			distributions()
+			for k := r.min; k <= count-r.min; k++ {
+				margin += lows[k-1].margin(r.dims) + highs[k].margin(r.dims)
+			}
+		}
+		if margin < bestMargin {
+			bestAxis, bestMargin = axis, margin
+		}
+	}
+	bestByMax, bestK, bestOverlap, bestArea := false, r.min, math.Inf(1), math.Inf(1)
+	for _, byMax := range []bool{false, true} {
+		sortBy(bestAxis, byMax)
+		distributions()
+		for k := r.min; k <= count-r.min; k++ {
+			overlap := lows[k-1].overlap(highs[k], r.dims)
+			area := lows[k-1].area(r.dims) + highs[k].area(r.dims)
+			if overlap < bestOverlap || overlap == bestOverlap && area < bestArea {
+				bestByMax, bestK, bestOverlap, bestArea = byMax, k, overlap, area
+			}
+		}
+	}
+	sortBy(bestAxis, bestByMax)
+	sibling := r.newNode(n.height)
+	for _, e := range entries[bestK:] {
+		sibling.add(e)
+	}
+	clear(entries[bestK:])
+	n.entries = entries[:bestK]
+	return sibling
+}
+
+// condense removes the nodes left with less than min entries from the leaf up to the root,
+// reinserts their items, and shortens the tree if the root has a single child.
+func (r *RTree) condense(n *node) {
+	var orphans []entry
+	for p := n.parent; p != nil; n, p = p, p.parent {
+		if len(n.entries) < r.min {
+			p.removeAt(p.indexOf(n))
+			orphans = collect(n, orphans)
+		} else {
+			p.entries[p.indexOf(n)].rect = n.bound(r.dims)
+		}
+	}
+	for !r.root.leaf() && len(r.root.entries) == 1 {
+		r.root = r.root.entries[0].child
+		r.root.parent = nil
+	}
+	if !r.root.leaf() && len(r.root.entries) == 0 {
+		r.root = r.newNode(0)
+	}
+	for _, e := range orphans {
+		var reinserted uint64
+		r.insert(e, 0, &reinserted)
+	}
+}
+
+// collect appends the entries of the items under the node to dst.
+func collect(n *node, dst []entry) []entry {
+	if n.leaf() {
+		return append(dst, n.entries...)
+	}
+	for _, e := range n.entries {
+		dst = collect(e.child, dst)
+	}
+	return dst
+}
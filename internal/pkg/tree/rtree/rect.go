@@ -0,0 +1,110 @@
+// Package rtree .
+package rtree
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+)
+
+// rect is an axis-aligned box on the axes indexed by a tree, boundaries included.
+// the coordinates are packed, a 2D tree keeps x and z in the first two elements.
+// a point is a rect of zero size, no epsilon is needed.
+type rect struct {
+	min, max [3]int32
+}
+
+// rectOf returns the rect of the bound on the axes.
+func rectOf(bound bounds.Bound, axes []int) rect {
+	var r rect
+	for i, axis := range axes {
+		r.min[i], r.max[i] = bound.Min[axis], bound.Max[axis]
+	}
+	return r
+}
+
+// union returns the rect enclosing both rects.
+func (r rect) union(o rect, dims int) rect {
+	for i := 0; i < dims; i++ {
+		r.min[i] = min(r.min[i], o.min[i])
+		r.max[i] = max(r.max[i], o.max[i])
+	}
+	return r
+}
+
+// intersects checks if the rects overlap, touching boundaries included.
+func (r rect) intersects(o rect, dims int) bool {
+	for i := 0; i < dims; i++ {
+		if r.max[i] < o.min[i] || o.max[i] < r.min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains checks if the rect encloses the other.
+func (r rect) contains(o rect, dims int) bool {
+	for i := 0; i < dims; i++ {
+		if o.min[i] < r.min[i] || r.max[i] < o.max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// area returns the area(volume if 3D) of the rect.
+func (r rect) area(dims int) float64 {
+	a := 1.0
+	for i := 0; i < dims; i++ {
+		a *= float64(r.max[i]) - float64(r.min[i])
+	}
+	return a
+}
+
+// margin returns the sum of the edges of the rect on each axis.
+func (r rect) margin(dims int) float64 {
+	m := 0.0
+	for i := 0; i < dims; i++ {
+		m += float64(r.max[i]) - float64(r.min[i])
+	}
+	return m
+}
+
+// overlap returns the area of the intersection of the rects, 0 if they don't intersect.
+func (r rect) overlap(o rect, dims int) float64 {
+	a := 1.0
+	for i := 0; i < dims; i++ {
+		low, high := max(r.min[i], o.min[i]), min(r.max[i], o.max[i])
+		if high < low {
+			return 0
+		}
+		a *= float64(high) - float64(low)
+	}
+	return a
+}
+
+// center returns the doubled center of the rect on the axis, doubled to stay an integer.
+func (r rect) center(axis int) int64 {
+	return int64(r.min[axis]) + int64(r.max[axis])
+}
+
+// minDistanceSquared returns the squared distance between the point and the rect, 0 if the point is within it.
+func (r rect) minDistanceSquared(p [3]float64, dims int) float64 {
+	sum := 0.0
+	for i := 0; i < dims; i++ {
+		if low := float64(r.min[i]); p[i] < low {
+			sum += (low - p[i]) * (low - p[i])
+		} else if high := float64(r.max[i]); p[i] > high {
+			sum += (p[i] - high) * (p[i] - high)
+		}
+	}
+	return sum
+}
+
+// centerDistanceSquared returns the squared distance between the centers of the rects, doubled like center.
+func (r rect) centerDistanceSquared(o rect, dims int) float64 {
+	sum := 0.0
+	for i := 0; i < dims; i++ {
+		d := float64(r.center(i) - o.center(i))
+		sum += d * d
+	}
+	return sum
+}
@@ -1,75 +1,558 @@
-// Package rtree .
+// Package rtree provides an implementation of the R*-tree for indexing the bounds of the entities.
 package rtree
 
 import (
+	"container/heap"
 	"fmt"
 	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/filter"
+	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
-	"github.com/dhconnelly/rtreego"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"unsafe"
 )
 
-// RTree .
+// RTree represents an R*-tree, the entities are indexed by their bounds(locations if they have no bound).
+//
+// An overflowing node reinserts its farthest entries once per insertion before splitting,
+// and the splits minimize the margins and the overlap of the nodes, see "The R*-tree" by Beckmann et al.
 type RTree struct {
-	origin   *rtreego.Rtree
-	entities map[int64]*REntity
+	dim      consts.Dim
+	axes     []int // The axes indexed, see axesOf.
+	dims     int   // The number of axes indexed.
+	min, max int   // The minimum/maximum number of entries of a node.
+	root     *node
+	entities map[int64]*item // Map of entity IDs to their items.
+	option   *option.OptionalSettings
 }
 
-// NewRTree .
-func NewRTree(dim consts.Dim, min, max int) *RTree {
-	return &RTree{
-		origin:   rtreego.NewTree(int(dim), min, max),
-		entities: make(map[int64]*REntity),
+// NewRTree creates a new RTree.
+// Parameters:
+// - dim: the dimension of the rtree, a 2D rtree indexes x and z like a quadtree.
+// - minChildren/maxChildren: the minimum/maximum branching factors, max is at least 2 and min is at most half of max.
+// - optional: variadic optional parameters to configure the rtree, mergeIf and the plane don't apply.
+func NewRTree(dim consts.Dim, minChildren, maxChildren int, optional ...option.Optional) *RTree {
+	maxChildren = max(maxChildren, 2)
+	r := &RTree{
+		dim:      dim,
+		axes:     axesOf(dim),
+		min:      min(max(minChildren, 1), maxChildren/2),
+		max:      maxChildren,
+		entities: make(map[int64]*item),
+		option:   option.OptionalDefault(),
 	}
+	r.dims = len(r.axes)
+	r.root = r.newNode(0)
+	for _, opt := range optional {
+		opt(r.option)
+	}
+	return r
 }
 
-// Add .
+// Add adds an entity to the rtree.
+// Returns true if the entity was added successfully, false if an entity with the same ID exists, see AddE.
 func (r *RTree) Add(entity siface.ISpatial) bool {
-	if e, err := NewREntity(entity); err != nil {
-		//TODO  log error
-		return false
-	} else {
-		r.entities[entity.GetID()] = e
-		r.origin.Insert(e)
-		return true
+	return r.AddE(entity) == nil
+}
+
+// AddE adds an entity to the rtree, returns siface.ErrInvalidBound or siface.ErrDuplicateID if it's rejected, see option.WithDuplicate.
+func (r *RTree) AddE(entity siface.ISpatial) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	if _, ok := r.entities[entity.GetID()]; ok {
+		return tree.AddDuplicate(r, entity, r.option.Duplicate())
+	}
+	it := &item{entity: entity}
+	r.entities[entity.GetID()] = it
+	var reinserted uint64
+	r.insert(entry{rect: r.rectOf(entity), item: it}, 0, &reinserted)
+	return nil
+}
+
+// AddBatch adds the entities to the rtree, the tree is packed again by sort-tile-recursive(STR)
+// if the batch is not smaller than the tree, which builds it bottom-up instead of splitting nodes on each insertion.
+// Returns the number of entities added, the ones whose ID is in the tree or repeated in the batch are added one by one
+// by the duplicate policy, see option.WithDuplicate, and the ones rejected by AddE are skipped.
+func (r *RTree) AddBatch(entities []siface.ISpatial) int {
+	entities, duplicates := tree.Admissible(entities, r.Contains)
+	batch := make([]entry, 0, len(entities))
+	for _, entity := range entities {
+		it := &item{entity: entity}
+		r.entities[entity.GetID()] = it
+		batch = append(batch, entry{rect: r.rectOf(entity), item: it})
 	}
+	if len(batch) < len(r.entities)-len(batch) {
+		for _, e := range batch {
+			var reinserted uint64
+			r.insert(e, 0, &reinserted)
+		}
+		return len(batch) + tree.AddEach(r, duplicates)
+	}
+	added := len(batch)
+	batch = collect(r.root, batch)
+	r.load(batch)
+	return added + tree.AddEach(r, duplicates)
 }
 
-// Remove .
+// Remove removes an entity from the rtree by its ID.
+// Returns true if the entity was removed successfully, false otherwise.
 func (r *RTree) Remove(entityId int64) bool {
-	if e, ok := r.entities[entityId]; ok {
-		r.origin.Delete(e)
-		delete(r.entities, entityId)
-		return true
+	return r.RemoveE(entityId) == nil
+}
+
+// RemoveE removes an entity from the rtree by its ID, returns siface.ErrNotFound if it's not in the rtree.
+func (r *RTree) RemoveE(entityId int64) error {
+	it, ok := r.entities[entityId]
+	if !ok {
+		return tree.NotFound(entityId)
 	}
-	return false
+	delete(r.entities, entityId)
+	leaf := it.leaf
+	leaf.removeAt(leaf.indexOfItem(it))
+	r.condense(leaf)
+	return nil
 }
 
+// Get returns the entity with the given ID.
+func (r *RTree) Get(entityId int64) (siface.ISpatial, bool) {
+	if it, ok := r.entities[entityId]; ok {
+		return it.entity, true
+	}
+	return nil, false
+}
+
+// Contains checks if an entity with the given ID is in the rtree.
+func (r *RTree) Contains(entityId int64) bool {
+	_, ok := r.entities[entityId]
+	return ok
+}
+
+// Update re-indexes an entity whose bound has changed.
+// The entity is updated in place if its new bound is still within its leaf, it's reinserted otherwise.
+// oldLocation is not needed by the rtree, the leaf holding the entity is looked up by ID.
+func (r *RTree) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	return r.UpdateE(entity, oldLocation) == nil
+}
+
+// UpdateE re-indexes an entity like Update, returns siface.ErrInvalidBound or siface.ErrNotFound if it fails.
+func (r *RTree) UpdateE(entity siface.ISpatial, _ geo.Vec3Int) error {
+	if err := tree.CheckBound(entity); err != nil {
+		return err
+	}
+	it, ok := r.entities[entity.GetID()]
+	if !ok {
+		return tree.NotFound(entity.GetID())
+	}
+	it.entity = entity
+	rc := r.rectOf(entity)
+	leaf := it.leaf
+	i := leaf.indexOfItem(it)
+	if leaf == r.root || r.rectOfNode(leaf).contains(rc, r.dims) {
+		leaf.entries[i].rect = rc
+		return nil
+	}
+	leaf.removeAt(i)
+	r.condense(leaf)
+	var reinserted uint64
+	r.insert(entry{rect: rc, item: it}, 0, &reinserted)
+	return nil
+}
+
+// GetSurroundingEntities finds entities whose bound intersects the sphere(circle on x/z if it's 2D) of radius around the center.
 func (r *RTree) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return r.AppendSurroundingEntities(make([]siface.ISpatial, 0), center, radius, filters...)
+}
+
+// AppendSurroundingEntities appends the entities found by GetSurroundingEntities to dst, and returns the extended slice.
+func (r *RTree) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	r.visitSurroundingEntities(center, radius, r.option.Metric(), func(entity siface.ISpatial) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters)
+	return dst
+}
+
+// VisitSurroundingEntities calls visit for each entity found by GetSurroundingEntities, until visit returns false.
+func (r *RTree) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	r.visitSurroundingEntities(center, radius, r.option.Metric(), visit, filters)
+}
+
+// GetSurroundingEntitiesWithMetric finds entities whose bound is within radius of the center measured by the metric,
+// that is the point of the bound closest to the center is. The euclidean distance is used if the metric is nil.
+func (r *RTree) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	r.visitSurroundingEntities(center, radius, metric, func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	}, filters)
+	return ret
+}
+
+// surroundings is a query of the entities within radius of the center,
+// it's reused through surroundingsPool so that a query doesn't allocate.
+type surroundings struct {
+	buf     [3]float32 // The buffer of the scaled center.
+	center  []float32  // The center, scaled if a scale function is set.
+	p       [3]float64 // The center on the axes indexed.
+	radius  float32
+	reach   rect // The rect enclosing the reach of the metric.
+	metric  util.DistanceMetric
+	closest [3]float32 // The buffer of the closest points of the bounds.
+}
+
+var surroundingsPool = sync.Pool{
+	New: func() any {
+		return &surroundings{}
+	},
+}
+
+// visitSurroundingEntities calls visit for each entity whose bound is within radius of the center measured by the metric,
+// until visit returns false.
+func (r *RTree) visitSurroundingEntities(center []float32, radius float32, metric util.DistanceMetric, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) {
+	q := surroundingsPool.Get().(*surroundings)
+	q.center, q.radius, q.metric = r.scale(q.buf[:], center), radius, metric
+	q.p = r.point(q.center)
+	if metric != nil {
+		// search the rect enclosing the reach, then measure the closest points of the bounds.
+		reach := metric.Reach(radius)
+		for i, axis := range r.axes {
+			q.reach.min[i] = clampInt32(math.Floor(q.p[i] - float64(reach[axis])))
+			q.reach.max[i] = clampInt32(math.Ceil(q.p[i] + float64(reach[axis])))
+		}
+	}
+	r.visitSurroundings(r.root, q, visit, filters)
+	*q = surroundings{}
+	surroundingsPool.Put(q)
+}
+
+// visitSurroundings visits the entities under the node for the query, returns false if the visit stopped.
+func (r *RTree) visitSurroundings(n *node, q *surroundings, visit func(entity siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool) bool {
+	for i := range n.entries {
+		e := &n.entries[i]
+		if q.metric == nil {
+			if e.rect.minDistanceSquared(q.p, r.dims) > float64(q.radius)*float64(q.radius) {
+				continue
+			}
+		} else if !e.rect.intersects(q.reach, r.dims) {
+			continue
+		}
+		if e.child != nil {
+			if !r.visitSurroundings(e.child, q, visit, filters) {
+				return false
+			}
+			continue
+		}
+		if q.metric != nil && !q.metric.Within(r.closest(q.closest[:], e.rect, q.center), q.center, q.radius) {
+			continue
+		}
+		if filter.Match(e.item.entity, filters...) && !visit(e.item.entity) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetEntitiesInBound finds entities whose bound intersects a box, boundaries included, a 2D rtree ignores y.
+func (r *RTree) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
 	ret := make([]siface.ISpatial, 0)
-	// build a search rect with the center and radius
-	rmin := []float64{float64(center[0] - radius), float64(center[1] - radius), float64(center[2] - radius)}
-	length := float64(radius * 2)
-	l := []float64{length, length, length}
+	r.search(r.root, rectOf(bound, r.axes), func(entity siface.ISpatial) bool {
+		if filter.Match(entity, filters...) {
+			ret = append(ret, entity)
+		}
+		return true
+	})
+	return ret
+}
 
-	if rect, err := rtreego.NewRect(rmin, l); err != nil {
+// search calls f for each entity under the node whose rect intersects rc, until f returns false.
+func (r *RTree) search(n *node, rc rect, f func(entity siface.ISpatial) bool) bool {
+	for i := range n.entries {
+		e := &n.entries[i]
+		if !e.rect.intersects(rc, r.dims) {
+			continue
+		}
+		if e.child != nil {
+			if !r.search(e.child, rc, f) {
+				return false
+			}
+		} else if !f(e.item.entity) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetIntersecting finds entities whose bound intersects a box, it's the same as GetEntitiesInBound.
+func (r *RTree) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return r.GetEntitiesInBound(bound, filters...)
+}
+
+// GetCollisionPairs finds all pairs of entities whose bounds overlap.
+func (r *RTree) GetCollisionPairs() [][2]siface.ISpatial {
+	return tree.CollisionPairs(r.rangeEntities, func(bound bounds.Bound) []siface.ISpatial {
+		return r.GetEntitiesInBound(bound)
+	})
+}
+
+// candidate is a node or an entry of an item waiting in the search queue of GetNearest.
+type candidate struct {
+	node *node
+	item *item
+	dist float64 // squared distance to the search center
+}
+
+// candidateQueue is a min-heap of candidates ordered by distance.
+type candidateQueue []candidate
+
+func (q candidateQueue) Len() int           { return len(q) }
+func (q candidateQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q candidateQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *candidateQueue) Push(x any) {
+	*q = append(*q, x.(candidate))
+}
+
+func (q *candidateQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// GetNearest finds the k entities nearest to a center point, sorted by ascending distance of their bounds.
+// maxDistance limits the distance of the entities, there is no limit if it's not positive.
+// The tree is traversed best-first, only the nodes that may hold one of the k nearest entities are opened.
+func (r *RTree) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	if k <= 0 {
 		return ret
-	} else {
-		entities := r.origin.SearchIntersect(rect)
-	outer:
-		for _, e := range entities {
-			if re, ok := e.(*REntity); ok {
-				for _, f := range filters {
-					if !f(re.ISpatial) {
-						continue outer
-					}
-				}
-				ret = append(ret, re.ISpatial)
+	}
+	var buf [3]float32
+	p := r.point(r.scale(buf[:], center))
+	within := func(dist float64) bool {
+		return maxDistance <= 0 || dist <= float64(maxDistance)*float64(maxDistance)
+	}
+	q := &candidateQueue{{node: r.root}}
+	for q.Len() > 0 && len(ret) < k {
+		c := heap.Pop(q).(candidate)
+		if c.item != nil {
+			ret = append(ret, c.item.entity)
+			continue
+		}
+		if !within(c.dist) {
+			// every remaining candidate is even farther.
+			break
+		}
+		for i := range c.node.entries {
+			e := &c.node.entries[i]
+			dist := e.rect.minDistanceSquared(p, r.dims)
+			if !within(dist) {
+				continue
+			}
+			if e.child != nil {
+				heap.Push(q, candidate{node: e.child, dist: dist})
+			} else if filter.Match(e.item.entity, filters...) {
+				heap.Push(q, candidate{item: e.item, dist: dist})
 			}
 		}
 	}
 	return ret
 }
 
+// All returns an iterator over all the entities in the rtree.
+func (r *RTree) All() iter.Seq[siface.ISpatial] {
+	return r.rangeEntities
+}
+
+// Within returns an iterator over the entities within a certain radius of a center point,
+// the search stops when the loop breaks.
+func (r *RTree) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		r.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+// Len returns the number of entities in the rtree.
+func (r *RTree) Len() int {
+	return len(r.entities)
+}
+
+// Stats returns the statistics of the rtree, all the leaves are at the same depth and hold all the entities.
+// Overflow is always 0 as the nodes split instead of holding more than the maximum.
+func (r *RTree) Stats() siface.Stats {
+	stats := siface.Stats{
+		Entities:       len(r.entities),
+		Depth:          r.root.height,
+		DepthHistogram: make([]int, r.root.height+1),
+		MemoryBytes:    int(unsafe.Sizeof(*r)) + tree.MapSize + len(r.entities)*(int(unsafe.Sizeof(item{}))+tree.MapEntrySize),
+	}
+	r.rangeNodes(r.root, 0, func(n *node, _ int) {
+		stats.Nodes++
+		stats.MemoryBytes += int(unsafe.Sizeof(*n)) + cap(n.entries)*int(unsafe.Sizeof(entry{}))
+		if n.leaf() {
+			stats.Leaves++
+			stats.MaxLeafEntities = max(stats.MaxLeafEntities, len(n.entries))
+		}
+	})
+	stats.DepthHistogram[stats.Depth] = stats.Leaves
+	if stats.Leaves > 0 {
+		stats.AvgLeafEntities = float64(stats.Entities) / float64(stats.Leaves)
+	}
+	return stats
+}
+
+// Snapshot writes the config and the entities of the rtree to w, see Restore.
+func (r *RTree) Snapshot(w io.Writer) error {
+	e := tree.NewEncoder(w, tree.KindRTree)
+	e.Uint8(uint8(r.dim))
+	e.Int32(int32(r.min))
+	e.Int32(int32(r.max))
+	tree.EncodeEntities(e, len(r.entities), r.rangeEntities)
+	return e.Close()
+}
+
+// Restore restores an rtree from a snapshot written by Snapshot, the entities are bulk loaded at their current bounds, see AddBatch.
+// Parameters:
+// - d: the decoder of the snapshot, whose header has been read.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil.
+// - optional: variadic optional parameters to configure the rtree.
+func Restore(d *tree.Decoder, resolve func(id int64) siface.ISpatial, optional ...option.Optional) (*RTree, error) {
+	dim, minChildren, maxChildren := consts.Dim(d.Uint8()), int(d.Int32()), int(d.Int32())
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	if dim != consts.Dim2 && dim != consts.Dim3 {
+		return nil, fmt.Errorf("unsupported dimension: %v", dim)
+	}
+	if minChildren < 1 || maxChildren < minChildren {
+		return nil, fmt.Errorf("%w: invalid branching factors %d/%d", tree.ErrSnapshot, minChildren, maxChildren)
+	}
+	entities := tree.DecodeEntities(d, resolve)
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	r := NewRTree(dim, minChildren, maxChildren, optional...)
+	r.AddBatch(entities)
+	return r, nil
+}
+
+// ToDot generates a dot file for the rtree, the nodes are labeled by their depths and indexes.
 func (r *RTree) ToDot() error {
-	return fmt.Errorf("rtree not support draw")
+	const fileName = "rtree.dot"
+	if r.option.DrawPath() == "" {
+		return fmt.Errorf("draw path not set")
+	}
+	if file, err := os.OpenFile(path.Join(r.option.DrawPath(), fileName), os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	} else {
+		defer file.Close()
+		return tree.WriteDot(tree.GetTemplate(), r.toPNode(), file)
+	}
+}
+
+// toPNode converts the rtree to the nodes of the dot template.
+func (r *RTree) toPNode() *tree.PNode {
+	p := &tree.PNode{}
+	names := make(map[*node]*tree.Elem)
+	r.rangeNodes(r.root, 0, func(n *node, depth int) {
+		el := &tree.Elem{Name: "root", Label: "root"}
+		if n.parent != nil {
+			index := n.parent.indexOf(n)
+			el = &tree.Elem{
+				Name:  fmt.Sprintf("node_%d", len(p.Nodes)),
+				Label: fmt.Sprintf("node_%d_%d", depth, index),
+			}
+			p.Edges = append(p.Edges, &tree.Pair{Parent: names[n.parent], Child: el})
+		}
+		names[n] = el
+		p.Nodes = append(p.Nodes, el)
+		if n.leaf() {
+			for _, e := range n.entries {
+				entity := &tree.Elem{
+					Name:  fmt.Sprintf("entity_%d", e.item.entity.GetID()),
+					Label: strconv.FormatInt(e.item.entity.GetID(), 10),
+				}
+				p.Entities = append(p.Entities, entity)
+				p.Edges = append(p.Edges, &tree.Pair{Parent: el, Child: entity})
+			}
+		}
+	})
+	return p
+}
+
+// rangeNodes calls f for the node and its descendants in depth-first order.
+func (r *RTree) rangeNodes(n *node, depth int, f func(n *node, depth int)) {
+	f(n, depth)
+	if !n.leaf() {
+		for _, e := range n.entries {
+			r.rangeNodes(e.child, depth+1, f)
+		}
+	}
+}
+
+// rectOf returns the rect of the bound of the entity, its location if it has no bound.
+func (r *RTree) rectOf(entity siface.ISpatial) rect {
+	return rectOf(tree.BoundOf(entity), r.axes)
+}
+
+// scale returns the center scaled by the scale function into buf if it's set, the center itself otherwise.
+func (r *RTree) scale(buf []float32, center []float32) []float32 {
+	if !r.option.HasScale() {
+		return center
+	}
+	v := r.option.ScaleFunc(center)
+	buf[0], buf[1], buf[2] = float32(v[0]), float32(v[1]), float32(v[2])
+	return buf
+}
+
+// point returns the center on the axes indexed.
+func (r *RTree) point(center []float32) [3]float64 {
+	var p [3]float64
+	for i, axis := range r.axes {
+		p[i] = float64(center[axis])
+	}
+	return p
+}
+
+// closest writes the point of the rect closest to the center into p and returns it,
+// the point takes the coordinates of the center on the axes not indexed.
+func (r *RTree) closest(p []float32, rc rect, center []float32) []float32 {
+	copy(p, center[:3])
+	for i, axis := range r.axes {
+		p[axis] = min(max(p[axis], float32(rc.min[i])), float32(rc.max[i]))
+	}
+	return p
+}
+
+// rangeEntities ranges all the entities in the rtree.
+func (r *RTree) rangeEntities(f func(entity siface.ISpatial) bool) {
+	for _, it := range r.entities {
+		if !f(it.entity) {
+			return
+		}
+	}
+}
+
+// axesOf returns the axes indexed by an rtree of the dimension, a 2D rtree indexes x and z like a quadtree.
+func axesOf(dim consts.Dim) []int {
+	if dim == consts.Dim2 {
+		return []int{0, 2}
+	}
+	return []int{0, 1, 2}
+}
+
+// clampInt32 converts v to int32, clamped to the range of int32.
+func clampInt32(v float64) int32 {
+	return int32(math.Max(math.MinInt32, math.Min(math.MaxInt32, v)))
 }
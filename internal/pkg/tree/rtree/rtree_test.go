@@ -2,9 +2,18 @@
 package rtree
 
 import (
+	"cmp"
 	"github.com/cozmo-zh/zearches/consts"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path"
+	"slices"
 	"testing"
 )
 
@@ -37,3 +46,325 @@ func Test_RTree_GetSurroundingEntities(t *testing.T) {
 	ret = rtree.GetSurroundingEntities([]float32{1, 1, 1}, 1)
 	assert.True(t, len(ret) == 0)
 }
+
+func Test_RTree_Update(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 1, 10)
+	entity := mocks.CreateMockSpatial(1, 10, 10, 10)
+	rtree.Add(entity)
+	moved := mocks.CreateMockSpatial(1, 20, 20, 20)
+	assert.True(t, rtree.Update(moved, entity.GetLocation()))
+	assert.Len(t, rtree.GetSurroundingEntities([]float32{10, 10, 10}, 1), 0)
+	ret := rtree.GetSurroundingEntities([]float32{20, 20, 20}, 1)
+	assert.Len(t, ret, 1)
+	assert.Equal(t, moved, ret[0])
+	assert.True(t, rtree.Remove(1))
+	assert.False(t, rtree.Update(moved, moved.GetLocation()))
+}
+
+func Test_RTree_GetAndContains(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 1, 10)
+	entity := mocks.CreateMockSpatial(1, 10, 10, 10)
+	rtree.Add(entity)
+	got, ok := rtree.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, entity, got)
+	assert.True(t, rtree.Contains(1))
+	rtree.Remove(1)
+	assert.False(t, rtree.Contains(1))
+}
+
+func Test_RTree_GetNearest(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 1, 3)
+	entities := make([]siface.ISpatial, 0)
+	for i := int32(1); i <= 10; i++ {
+		entity := mocks.CreateMockSpatial(int64(i), i*10, i*10, i*10)
+		entities = append(entities, entity)
+		rtree.Add(entity)
+	}
+	ret := rtree.GetNearest([]float32{32, 32, 32}, 3, 0)
+	assert.Equal(t, []siface.ISpatial{entities[2], entities[3], entities[1]}, ret)
+	ret = rtree.GetNearest([]float32{32, 32, 32}, 3, 10)
+	assert.Equal(t, []siface.ISpatial{entities[2]}, ret)
+	ret = rtree.GetNearest([]float32{32, 32, 32}, 2, 0, func(entity siface.ISpatial) bool {
+		return entity.GetID() > 5
+	})
+	assert.Equal(t, []siface.ISpatial{entities[5], entities[6]}, ret)
+	assert.Empty(t, rtree.GetNearest([]float32{32, 32, 32}, 0, 0))
+}
+
+func Test_RTree_GetEntitiesInBound(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 1, 10)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10)
+	entity2 := mocks.CreateMockSpatial(2, 20, 20, 20)
+	sized := mocks.CreateMockSpatial(3, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(30, 30, 30), geo.NewVec3Int(70, 70, 70)))
+	rtree.Add(entity1)
+	rtree.Add(entity2)
+	rtree.Add(sized)
+	ret := rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(10, 10, 10), geo.NewVec3Int(20, 20, 20)))
+	assert.ElementsMatch(t, []siface.ISpatial{entity1, entity2}, ret)
+	// intersects the bound of the sized entity but not its location
+	ret = rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(25, 25, 25), geo.NewVec3Int(30, 30, 30)))
+	assert.Equal(t, []siface.ISpatial{sized}, ret)
+}
+
+func Test_RTree_GetCollisionPairs(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 1, 10)
+	entity1 := mocks.CreateMockSpatial(1, 10, 10, 10, bounds.NewBound(geo.NewVec3Int(5, 5, 5), geo.NewVec3Int(15, 15, 15)))
+	entity2 := mocks.CreateMockSpatial(2, 20, 20, 20, bounds.NewBound(geo.NewVec3Int(15, 15, 15), geo.NewVec3Int(25, 25, 25)))
+	entity3 := mocks.CreateMockSpatial(3, 90, 90, 90)
+	rtree.Add(entity1)
+	rtree.Add(entity2)
+	rtree.Add(entity3)
+	assert.Equal(t, [][2]siface.ISpatial{{entity1, entity2}}, rtree.GetCollisionPairs())
+}
+
+func Test_RTree_GetSurroundingEntitiesMatchBruteForce(t *testing.T) {
+	// distanceSquared between the center and the bound on the axes.
+	distanceSquared := func(center []float32, bound bounds.Bound, axes []int) float64 {
+		sum := 0.0
+		for _, axis := range axes {
+			v := float64(center[axis])
+			if low := float64(bound.Min[axis]); v < low {
+				sum += (low - v) * (low - v)
+			} else if high := float64(bound.Max[axis]); v > high {
+				sum += (v - high) * (v - high)
+			}
+		}
+		return sum
+	}
+	for _, dim := range []consts.Dim{consts.Dim2, consts.Dim3} {
+		rtree := NewRTree(dim, 2, 8)
+		r := rand.New(rand.NewSource(1))
+		entities := make([]siface.ISpatial, 0)
+		for i := 0; i < 300; i++ {
+			x, y, z := r.Int31n(101), r.Int31n(101), r.Int31n(101)
+			entity := mocks.CreateMockSpatial(int64(i), x, y, z)
+			if i%3 == 0 {
+				entity = mocks.CreateMockSpatial(int64(i), x, y, z, bounds.NewBound(geo.NewVec3Int(x-2, y-2, z-2), geo.NewVec3Int(x+3, y+3, z+3)))
+			}
+			entities = append(entities, entity)
+			assert.True(t, rtree.Add(entity))
+		}
+		for _, center := range [][]float32{{30, 40, 50}, {0, 0, 0}, {50.5, 50.5, 50.5}} {
+			for _, radius := range []float32{5, 20, 35.5} {
+				expected := make([]siface.ISpatial, 0)
+				for _, entity := range entities {
+					if distanceSquared(center, entity.GetBound(), axesOf(dim)) <= float64(radius)*float64(radius) {
+						expected = append(expected, entity)
+					}
+				}
+				assert.ElementsMatch(t, expected, rtree.GetSurroundingEntities(center, radius))
+			}
+		}
+	}
+}
+
+func Test_RTree_2DIgnoresY(t *testing.T) {
+	rtree := NewRTree(consts.Dim2, 2, 8)
+	entity := mocks.CreateMockSpatial(1, 10, 500, 10)
+	assert.True(t, rtree.Add(entity))
+	assert.Equal(t, []siface.ISpatial{entity}, rtree.GetSurroundingEntities([]float32{10, 0, 10}, 1))
+	assert.Equal(t, []siface.ISpatial{entity}, rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 0, 10))))
+	assert.Equal(t, []siface.ISpatial{entity}, rtree.GetNearest([]float32{12, 0, 10}, 1, 2))
+	// the corner of the enclosing rect is beyond the radius.
+	assert.Empty(t, rtree.GetSurroundingEntities([]float32{14, 0, 14}, 5))
+}
+
+// checkTree checks the invariants of the rtree: the rects of the nodes enclose their entries,
+// the nodes other than the root hold min to max entries, the leaves are at the same depth and the links are consistent.
+func checkTree(t *testing.T, rtree *RTree) {
+	count := 0
+	rtree.rangeNodes(rtree.root, 0, func(n *node, depth int) {
+		assert.Equal(t, rtree.root.height-depth, n.height)
+		assert.LessOrEqual(t, len(n.entries), rtree.max)
+		if n != rtree.root {
+			assert.GreaterOrEqual(t, len(n.entries), rtree.min)
+			assert.True(t, rtree.rectOfNode(n).contains(n.bound(rtree.dims), rtree.dims))
+		}
+		for _, e := range n.entries {
+			if n.leaf() {
+				count++
+				assert.Same(t, n, e.item.leaf)
+				assert.Equal(t, rtree.rectOf(e.item.entity), e.rect)
+			} else {
+				assert.Same(t, n, e.child.parent)
+			}
+		}
+	})
+	assert.Equal(t, len(rtree.entities), count)
+}
+
+func Test_RTree_RandomInvariants(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, dim := range []consts.Dim{consts.Dim2, consts.Dim3} {
+		rtree := NewRTree(dim, 2, 6)
+		for i := 0; i < 3000; i++ {
+			id := int64(r.Intn(500))
+			x, y, z := r.Int31n(1000), r.Int31n(1000), r.Int31n(1000)
+			switch {
+			case !rtree.Contains(id):
+				assert.True(t, rtree.Add(mocks.CreateMockSpatial(id, x, y, z)))
+			case i%3 == 0:
+				assert.True(t, rtree.Remove(id))
+			default:
+				entity, _ := rtree.Get(id)
+				assert.True(t, rtree.Update(mocks.CreateMockSpatial(id, x, y, z), entity.GetLocation()))
+			}
+		}
+		checkTree(t, rtree)
+		assert.Equal(t, len(rtree.entities), len(rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000)))))
+		for id := range rtree.entities {
+			assert.True(t, rtree.Remove(id))
+		}
+		checkTree(t, rtree)
+		assert.True(t, rtree.root.leaf())
+	}
+}
+
+func Test_RTree_UpdateInPlace(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 2, 4)
+	for i := int32(0); i < 20; i++ {
+		rtree.Add(mocks.CreateMockSpatial(int64(i), i*10, i*10, i*10))
+	}
+	it := rtree.entities[5]
+	leaf := it.leaf
+	bound := rtree.rectOfNode(leaf)
+	// still within the rect of its leaf.
+	moved := mocks.CreateMockSpatial(5, bound.max[0], bound.max[1], bound.max[2])
+	assert.True(t, rtree.Update(moved, geo.NewVec3Int(50, 50, 50)))
+	assert.Same(t, leaf, it.leaf)
+	assert.Equal(t, []siface.ISpatial{moved}, rtree.GetNearest([]float32{float32(bound.max[0]), float32(bound.max[1]), float32(bound.max[2])}, 1, 0))
+	// out of the rect of its leaf.
+	moved = mocks.CreateMockSpatial(5, 1000, 1000, 1000)
+	assert.True(t, rtree.Update(moved, geo.NewVec3Int(0, 0, 0)))
+	assert.Equal(t, []siface.ISpatial{moved}, rtree.GetSurroundingEntities([]float32{1000, 1000, 1000}, 1))
+	checkTree(t, rtree)
+}
+
+func Test_RTree_Points(t *testing.T) {
+	// the entities without size and at the same location.
+	rtree := NewRTree(consts.Dim3, 2, 4)
+	for i := int64(1); i <= 20; i++ {
+		assert.True(t, rtree.Add(mocks.CreateMockSpatial(i, 10, 10, 10)))
+	}
+	assert.False(t, rtree.Add(mocks.CreateMockSpatial(1, 20, 20, 20)))
+	checkTree(t, rtree)
+	assert.Len(t, rtree.GetSurroundingEntities([]float32{10, 10, 10}, 0), 20)
+	assert.Len(t, rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(10, 10, 10), geo.NewVec3Int(10, 10, 10))), 20)
+	assert.Empty(t, rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(11, 11, 11), geo.NewVec3Int(20, 20, 20))))
+}
+
+func Test_RTree_GetNearestMatchBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	rtree := NewRTree(consts.Dim3, 3, 8)
+	entities := make([]siface.ISpatial, 0)
+	for i := 0; i < 500; i++ {
+		entity := mocks.CreateMockSpatial(int64(i), r.Int31n(1000), r.Int31n(1000), r.Int31n(1000))
+		entities = append(entities, entity)
+		rtree.Add(entity)
+	}
+	center := []float32{500, 500, 500}
+	distance := func(entity siface.ISpatial) float64 {
+		return rectOf(entity.GetBound(), rtree.axes).minDistanceSquared([3]float64{500, 500, 500}, 3)
+	}
+	slices.SortStableFunc(entities, func(a, b siface.ISpatial) int {
+		return cmp.Compare(distance(a), distance(b))
+	})
+	ret := rtree.GetNearest(center, 20, 0)
+	assert.Len(t, ret, 20)
+	for i := range ret {
+		assert.Equal(t, distance(entities[i]), distance(ret[i]))
+	}
+}
+
+func Test_RTree_AddBatch(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 2, 8)
+	rtree.Add(mocks.CreateMockSpatial(0, 0, 0, 0))
+	entities := make([]siface.ISpatial, 0)
+	for i := int32(0); i < 1000; i++ {
+		entities = append(entities, mocks.CreateMockSpatial(int64(i), i, i*7%1000, i*13%1000))
+	}
+	assert.Equal(t, 999, rtree.AddBatch(entities))
+	checkTree(t, rtree)
+	// smaller than the tree, inserted one by one.
+	assert.Equal(t, 1, rtree.AddBatch([]siface.ISpatial{mocks.CreateMockSpatial(1000, 1, 1, 1)}))
+	checkTree(t, rtree)
+	assert.Len(t, rtree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))), 1001)
+}
+
+func Test_RTree_Stats(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 2, 4)
+	stats := rtree.Stats()
+	assert.Equal(t, 1, stats.Nodes)
+	assert.Equal(t, 0, stats.Depth)
+	for i := int32(0); i < 100; i++ {
+		rtree.Add(mocks.CreateMockSpatial(int64(i), i, i, i))
+	}
+	stats = rtree.Stats()
+	assert.Equal(t, 100, stats.Entities)
+	assert.Equal(t, rtree.root.height, stats.Depth)
+	assert.Equal(t, stats.Leaves, stats.DepthHistogram[stats.Depth])
+	assert.LessOrEqual(t, stats.MaxLeafEntities, 4)
+	assert.Greater(t, stats.Nodes, stats.Leaves)
+}
+
+func Test_RTree_Scale(t *testing.T) {
+	rtree := NewRTree(consts.Dim3, 2, 8, option.WithScale(func(v []float32) geo.Vec3Int {
+		return geo.NewVec3Int(int32(v[0]*10), int32(v[1]*10), int32(v[2]*10))
+	}))
+	entity := mocks.CreateMockSpatial(1, 10, 10, 10)
+	rtree.Add(entity)
+	assert.Equal(t, []siface.ISpatial{entity}, rtree.GetSurroundingEntities([]float32{1, 1, 1}, 1))
+	assert.Equal(t, []siface.ISpatial{entity}, rtree.GetNearest([]float32{1.5, 1, 1}, 1, 6))
+}
+
+func Test_RTree_ToDot(t *testing.T) {
+	dir := t.TempDir()
+	rtree := NewRTree(consts.Dim3, 1, 2, option.WithDrawPath(dir))
+	for i := int32(1); i <= 3; i++ {
+		rtree.Add(mocks.CreateMockSpatial(int64(i), i*10, i*10, i*10))
+	}
+	assert.Nil(t, rtree.ToDot())
+	content, err := os.ReadFile(path.Join(dir, "rtree.dot"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "root -> node_1")
+	assert.Contains(t, string(content), "-> entity_3")
+	assert.NotNil(t, NewRTree(consts.Dim3, 1, 2).ToDot())
+}
+
+func benchmarkEntities(n int) []siface.ISpatial {
+	r := rand.New(rand.NewSource(4))
+	ret := make([]siface.ISpatial, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, mocks.CreateMockSpatial(int64(i), r.Int31n(10000), r.Int31n(10000), r.Int31n(10000)))
+	}
+	return ret
+}
+
+const benchmarkSize = 20000
+
+func BenchmarkRTree_Insert(b *testing.B) {
+	entities := benchmarkEntities(benchmarkSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rtree := NewRTree(consts.Dim3, 8, 32)
+		for _, entity := range entities {
+			rtree.Add(entity)
+		}
+	}
+}
+
+func BenchmarkRTree_Search(b *testing.B) {
+	entities := benchmarkEntities(benchmarkSize)
+	rtree := NewRTree(consts.Dim3, 8, 32)
+	for _, entity := range entities {
+		rtree.Add(entity)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := entities[i%len(entities)].GetLocation()
+		rtree.GetEntitiesInBound(bounds.NewBound(
+			geo.NewVec3Int(l.X()-500, l.Y()-500, l.Z()-500), geo.NewVec3Int(l.X()+500, l.Y()+500, l.Z()+500)))
+	}
+}
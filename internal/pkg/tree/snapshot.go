@@ -0,0 +1,324 @@
+// Package tree .
+package tree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/treenode"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"io"
+	"math"
+	"slices"
+)
+
+// Snapshot format, all the numbers are little endian:
+//
+//	magic "ZSNP" | version uint16 | kind uint8 | the config of the kind | the entities of the kind
+//
+// The entities are written as (id int64, location 3 x int32), the trees made of nodes write their nodes
+// in depth-first order: the entities of the node, a divided flag and the children if it's divided.
+//
+// Version 2 adds the loose factor to the config of the octree and the quadtree, version 3 adds their out-of-bounds policy,
+// the older versions are still read.
+const (
+	SnapshotMagic   = "ZSNP"
+	SnapshotVersion = uint16(3)
+)
+
+// Kind identifies the kind of search in a snapshot.
+type Kind uint8
+
+const (
+	KindOctree Kind = iota + 1
+	KindQuadtree
+	KindGrid
+	KindCrossList
+	KindRTree
+)
+
+// ErrSnapshot is returned when a snapshot is malformed or of an unsupported version.
+var ErrSnapshot = errors.New("invalid snapshot")
+
+// Encoder writes the values of a snapshot, the first error is kept and the later writes are ignored.
+type Encoder struct {
+	w   *bufio.Writer
+	buf [8]byte
+	err error
+}
+
+// NewEncoder creates an Encoder writing the header of a snapshot of the kind.
+func NewEncoder(w io.Writer, kind Kind) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w)}
+	e.write([]byte(SnapshotMagic))
+	e.Uint16(SnapshotVersion)
+	e.Uint8(uint8(kind))
+	return e
+}
+
+func (e *Encoder) write(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// Uint8 writes a byte.
+func (e *Encoder) Uint8(v uint8) {
+	e.buf[0] = v
+	e.write(e.buf[:1])
+}
+
+// Bool writes a bool as a byte.
+func (e *Encoder) Bool(v bool) {
+	if v {
+		e.Uint8(1)
+	} else {
+		e.Uint8(0)
+	}
+}
+
+// Uint16 writes an uint16.
+func (e *Encoder) Uint16(v uint16) {
+	binary.LittleEndian.PutUint16(e.buf[:2], v)
+	e.write(e.buf[:2])
+}
+
+// Int32 writes an int32.
+func (e *Encoder) Int32(v int32) {
+	binary.LittleEndian.PutUint32(e.buf[:4], uint32(v))
+	e.write(e.buf[:4])
+}
+
+// Int64 writes an int64.
+func (e *Encoder) Int64(v int64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], uint64(v))
+	e.write(e.buf[:8])
+}
+
+// Float64 writes a float64.
+func (e *Encoder) Float64(v float64) {
+	e.Int64(int64(math.Float64bits(v)))
+}
+
+// Vec writes the 3 elements of a vector.
+func (e *Encoder) Vec(v geo.Vec3Int) {
+	for i := 0; i < 3; i++ {
+		e.Int32(v[i])
+	}
+}
+
+// Bound writes the min and max of a bound.
+func (e *Encoder) Bound(b bounds.Bound) {
+	e.Vec(b.Min)
+	e.Vec(b.Max)
+}
+
+// Entity writes the ID and the location of an entity.
+func (e *Encoder) Entity(entity siface.ISpatial) {
+	e.Int64(entity.GetID())
+	e.Vec(entity.GetLocation())
+}
+
+// Close flushes the snapshot and returns the first error.
+func (e *Encoder) Close() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// Decoder reads the values of a snapshot, the first error is kept and the later reads return zeros.
+//
+// The decoder buffers the reader, it may read beyond the end of the snapshot.
+type Decoder struct {
+	r       *bufio.Reader
+	buf     [8]byte
+	err     error
+	version uint16
+}
+
+// NewDecoder creates a Decoder reading the header of a snapshot, it returns the kind of the snapshot.
+func NewDecoder(r io.Reader) (*Decoder, Kind, error) {
+	d := &Decoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(SnapshotMagic))
+	d.read(magic)
+	version := d.Uint16()
+	kind := Kind(d.Uint8())
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+	if string(magic) != SnapshotMagic {
+		return nil, 0, fmt.Errorf("%w: bad magic %q", ErrSnapshot, magic)
+	}
+	if version < 1 || version > SnapshotVersion {
+		return nil, 0, fmt.Errorf("%w: unsupported version %d", ErrSnapshot, version)
+	}
+	d.version = version
+	return d, kind, nil
+}
+
+// Version returns the version of the snapshot.
+func (d *Decoder) Version() uint16 {
+	return d.version
+}
+
+func (d *Decoder) read(b []byte) {
+	if d.err != nil {
+		clear(b)
+		return
+	}
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		clear(b)
+	}
+}
+
+// Uint8 reads a byte.
+func (d *Decoder) Uint8() uint8 {
+	d.read(d.buf[:1])
+	return d.buf[0]
+}
+
+// Bool reads a bool written by Encoder.Bool.
+func (d *Decoder) Bool() bool {
+	return d.Uint8() != 0
+}
+
+// Uint16 reads an uint16.
+func (d *Decoder) Uint16() uint16 {
+	d.read(d.buf[:2])
+	return binary.LittleEndian.Uint16(d.buf[:2])
+}
+
+// Int32 reads an int32.
+func (d *Decoder) Int32() int32 {
+	d.read(d.buf[:4])
+	return int32(binary.LittleEndian.Uint32(d.buf[:4]))
+}
+
+// Int64 reads an int64.
+func (d *Decoder) Int64() int64 {
+	d.read(d.buf[:8])
+	return int64(binary.LittleEndian.Uint64(d.buf[:8]))
+}
+
+// Float64 reads a float64.
+func (d *Decoder) Float64() float64 {
+	return math.Float64frombits(uint64(d.Int64()))
+}
+
+// Vec reads the 3 elements of a vector.
+func (d *Decoder) Vec() geo.Vec3Int {
+	return geo.NewVec3Int(d.Int32(), d.Int32(), d.Int32())
+}
+
+// Bound reads the min and max of a bound.
+func (d *Decoder) Bound() bounds.Bound {
+	return bounds.NewBound(d.Vec(), d.Vec())
+}
+
+// Entity reads the ID and the location of an entity.
+func (d *Decoder) Entity() (int64, geo.Vec3Int) {
+	return d.Int64(), d.Vec()
+}
+
+// Count reads a non-negative count written by Encoder.Int32.
+func (d *Decoder) Count() int {
+	n := d.Int32()
+	if n < 0 && d.err == nil {
+		d.err = fmt.Errorf("%w: negative count %d", ErrSnapshot, n)
+	}
+	return max(int(n), 0)
+}
+
+// Err returns the first error.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// EncodeEntities writes the count and the entities ranged by rangeEntities, for the searches without nodes.
+func EncodeEntities(e *Encoder, count int, rangeEntities func(f func(entity siface.ISpatial) bool)) {
+	e.Int32(int32(count))
+	rangeEntities(func(entity siface.ISpatial) bool {
+		e.Entity(entity)
+		return true
+	})
+}
+
+// DecodeEntities reads the entities written by EncodeEntities and resolves them by their IDs,
+// the ones resolved to nil are skipped.
+func DecodeEntities(d *Decoder, resolve func(id int64) siface.ISpatial) []siface.ISpatial {
+	count := d.Count()
+	ret := make([]siface.ISpatial, 0, min(count, 1<<16))
+	for i := 0; i < count && d.Err() == nil; i++ {
+		id, _ := d.Entity()
+		if d.Err() != nil {
+			break
+		}
+		if entity := resolve(id); entity != nil {
+			ret = append(ret, entity)
+		}
+	}
+	return ret
+}
+
+// EncodeNodes writes the node and its descendants in depth-first order.
+func EncodeNodes(e *Encoder, n *treenode.TreeNode) {
+	e.Int32(int32(n.Size()))
+	n.RangeEntities(func(entity siface.ISpatial) bool {
+		e.Entity(entity)
+		return true
+	})
+	divided := !n.IsLeaf()
+	e.Bool(divided)
+	if divided {
+		for i := 0; i < n.Children().ChildrenCount(); i++ {
+			EncodeNodes(e, n.Children().GetChild(i))
+		}
+	}
+}
+
+// DecodeNodes restores the nodes written by EncodeNodes under the node, which should be an empty leaf.
+// The entities are resolved by their IDs and put back to the nodes they were in, the ones resolved to nil are skipped.
+// Returns the entities which are not at their snapshot locations anymore, they should be added to the tree again.
+func DecodeNodes(d *Decoder, n *treenode.TreeNode, resolve func(id int64) siface.ISpatial) []siface.ISpatial {
+	var moved []siface.ISpatial
+	root := n
+	var decode func(n *treenode.TreeNode)
+	decode = func(n *treenode.TreeNode) {
+		for i, count := 0, d.Count(); i < count && d.Err() == nil; i++ {
+			id, location := d.Entity()
+			if d.Err() != nil {
+				return
+			}
+			entity := resolve(id)
+			if entity == nil {
+				continue
+			}
+			// the ones out of the bound of the tree were placed by its out-of-bounds policy, they go back as well.
+			if slices.Equal(entity.GetLocation(), location) && (n.Contains(entity) || !root.Contains(entity)) {
+				n.Put(entity)
+			} else {
+				moved = append(moved, entity)
+			}
+		}
+		if d.Bool() && d.Err() == nil {
+			if n.Depth() >= n.MaxDepth()-1 {
+				d.err = fmt.Errorf("%w: node deeper than the max depth %d", ErrSnapshot, n.MaxDepth())
+				return
+			}
+			n.Split()
+			for i := 0; i < n.Children().ChildrenCount() && d.Err() == nil; i++ {
+				decode(n.Children().GetChild(i))
+			}
+		}
+	}
+	decode(n)
+	return moved
+}
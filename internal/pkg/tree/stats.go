@@ -0,0 +1,50 @@
+// Package tree .
+package tree
+
+import (
+	"container/list"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/treenode"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"unsafe"
+)
+
+const (
+	// MapEntrySize is the estimated memory of an entry of a map from an ID to a pointer, including the overhead of the buckets.
+	MapEntrySize = 40
+	// MapSize is the estimated memory of an empty map.
+	MapSize = 48
+	// vecSize is the memory of the backing array of a geo.Vec3Int.
+	vecSize = int(unsafe.Sizeof([3]int32{}))
+)
+
+// StatsOf collects the statistics of the tree of the root.
+// The average of the leaves only counts the entities held by the leaves, not the ones straddling the internal nodes.
+func StatsOf(root *treenode.TreeNode) siface.Stats {
+	var stats siface.Stats
+	leafEntities := 0
+	for n := range root.Nodes() {
+		stats.Nodes++
+		stats.MemoryBytes += int(unsafe.Sizeof(treenode.TreeNode{})+unsafe.Sizeof(list.List{})) + 3*vecSize + MapSize
+		stats.Entities += n.Size()
+		stats.MemoryBytes += n.Size() * (int(unsafe.Sizeof(list.Element{})) + 2*MapEntrySize)
+		if !n.IsLeaf() {
+			stats.MemoryBytes += n.Children().ChildrenCount() * int(unsafe.Sizeof(root))
+			continue
+		}
+		stats.Leaves++
+		leafEntities += n.Size()
+		for len(stats.DepthHistogram) <= n.Depth() {
+			stats.DepthHistogram = append(stats.DepthHistogram, 0)
+		}
+		stats.DepthHistogram[n.Depth()]++
+		stats.Depth = max(stats.Depth, n.Depth())
+		stats.MaxLeafEntities = max(stats.MaxLeafEntities, n.Size())
+		if n.Depth() >= n.MaxDepth()-1 && n.Size() > n.Capacity() {
+			stats.Overflow += n.Size()
+		}
+	}
+	if stats.Leaves > 0 {
+		stats.AvgLeafEntities = float64(leafEntities) / float64(stats.Leaves)
+	}
+	return stats
+}
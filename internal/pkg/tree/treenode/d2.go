@@ -15,11 +15,18 @@ const (
 // D2 .
 type D2 struct {
 	children [childrenCountD2]*TreeNode
+	plane    consts.Plane
+	axes     []int // The axes of the plane.
 }
 
-// NewD2 .
+// NewD2 creates a D2 on the x/z plane.
 func NewD2() *D2 {
-	return &D2{}
+	return NewD2WithPlane(consts.PlaneXZ)
+}
+
+// NewD2WithPlane creates a D2 on the given plane, the other axis is ignored.
+func NewD2WithPlane(plane consts.Plane) *D2 {
+	return &D2{plane: plane, axes: plane.Axes()}
 }
 
 // Divide the node into 4 children and move entities to children.
@@ -29,22 +36,18 @@ func (d *D2) Divide(parent *TreeNode, depth int) {
 	*   --.--
 	*   |0|3|
 	 */
-	min0 := geo.NewVec3Int(parent.Bound().Min.X(), 0, parent.Bound().Min.Z())
-	max0 := geo.NewVec3Int(parent.Bound().Center.X(), 0, parent.Bound().Center.Z())
-	bound0 := bounds.NewBound(min0, max0)
-
-	min1 := geo.NewVec3Int(parent.Bound().Min.X(), 0, parent.Bound().Center.Z())
-	max1 := geo.NewVec3Int(parent.Bound().Center.X(), 0, parent.Bound().Max.Z())
-	bound1 := bounds.NewBound(min1, max1)
-
-	min2 := geo.NewVec3Int(parent.Bound().Center.X(), 0, parent.Bound().Center.Z())
-	max2 := geo.NewVec3Int(parent.Bound().Max.X(), 0, parent.Bound().Max.Z())
-	bound2 := bounds.NewBound(min2, max2)
-
-	min3 := geo.NewVec3Int(parent.Bound().Center.X(), 0, parent.Bound().Min.Z())
-	max3 := geo.NewVec3Int(parent.Bound().Max.X(), 0, parent.Bound().Center.Z())
-	bound3 := bounds.NewBound(min3, max3)
-
+	// vec returns the point of u and v on the plane, the other axis is 0.
+	vec := func(u, v int32) geo.Vec3Int {
+		p := geo.NewVec3Int(0, 0, 0)
+		p[d.axes[0]], p[d.axes[1]] = u, v
+		return p
+	}
+	u, v := d.axes[0], d.axes[1]
+	b := parent.Bound()
+	bound0 := bounds.NewBound(vec(b.Min[u], b.Min[v]), vec(b.Center[u], b.Center[v]))
+	bound1 := bounds.NewBound(vec(b.Min[u], b.Center[v]), vec(b.Center[u], b.Max[v]))
+	bound2 := bounds.NewBound(vec(b.Center[u], b.Center[v]), vec(b.Max[u], b.Max[v]))
+	bound3 := bounds.NewBound(vec(b.Center[u], b.Min[v]), vec(b.Max[u], b.Center[v]))
 	// Create children.
 	maxDepth := parent.MaxDepth()
 	capacity := parent.Capacity()
@@ -66,6 +69,16 @@ func (d *D2) GetChild(index int) *TreeNode {
 	return d.children[index]
 }
 
+// SetChild replaces the child at the specified index.
+func (d *D2) SetChild(index int, child *TreeNode) {
+	d.children[index] = child
+}
+
+// Axes returns the indexes of the axes of the plane.
+func (d *D2) Axes() []int {
+	return d.axes
+}
+
 // Clear removes all children.
 func (d *D2) Clear() {
 	d.children = [childrenCountD2]*TreeNode{}
@@ -79,18 +92,72 @@ func (d *D2) Clear() {
 // Returns:
 // - true if the entity is within the bounds, false otherwise.
 func (d *D2) Contains(n *TreeNode, spatial siface.ISpatial) bool {
-	if n.bound.Min.X() <= spatial.GetLocation().X() && spatial.GetLocation().X() <= n.bound.Max.X() &&
-		n.bound.Min.Z() <= spatial.GetLocation().Z() && spatial.GetLocation().Z() <= n.bound.Max.Z() {
-		return true
+	return d.ContainsLocation(n, spatial.GetLocation())
+}
+
+// ContainsLocation checks if the location is within the bounds of the node on the plane.
+func (d *D2) ContainsLocation(n *TreeNode, location geo.Vec3Int) bool {
+	return n.bound.ContainsAxes(location, d.axes...)
+}
+
+// ChildIndex returns the index of the first child containing the location on the plane, see the layout in Divide,
+// a location on the center belongs to the child of the smaller index.
+func (d *D2) ChildIndex(n *TreeNode, location geo.Vec3Int) int {
+	u, v := location[d.axes[0]], location[d.axes[1]]
+	cu, cv := n.bound.Center[d.axes[0]], n.bound.Center[d.axes[1]]
+	switch {
+	case u <= cu && v <= cv:
+		return 0
+	case u <= cu:
+		return 1
+	case v >= cv:
+		return 2
+	default:
+		return 3
 	}
-	return false
 }
 
-// Intersects checks if the bound intersects with the node.
+// Intersects checks if the bound intersects with the node on the plane, the loose bound of the node if the tree is loose.
 func (d *D2) Intersects(n *TreeNode, bound bounds.Bound) bool {
-	if n.bound.Min.X() <= bound.Max.X() && bound.Min.X() <= n.bound.Max.X() &&
-		n.bound.Min.Z() <= bound.Max.Z() && bound.Min.Z() <= n.bound.Max.Z() {
-		return true
-	}
-	return false
+	return n.looseBound.IntersectsAxes(bound, d.axes...)
+}
+
+// IntersectsBound checks if the bounds intersect on the plane.
+func (d *D2) IntersectsBound(a, b bounds.Bound) bool {
+	return a.IntersectsAxes(b, d.axes...)
+}
+
+// ContainsBound checks if the inner bound is within the outer bound on the plane.
+func (d *D2) ContainsBound(outer, inner bounds.Bound) bool {
+	return outer.EnclosesAxes(inner, d.axes...)
+}
+
+// InBound checks if the location is within the bound on the plane.
+func (d *D2) InBound(bound bounds.Bound, location geo.Vec3Int) bool {
+	return bound.ContainsAxes(location, d.axes...)
+}
+
+// MinDistanceSquared returns the squared distance between the location and the node on the plane,
+// the loose bound of the node if the tree is loose.
+func (d *D2) MinDistanceSquared(n *TreeNode, location geo.Vec3Int) float64 {
+	return d.BoundDistanceSquared(n.looseBound, location)
+}
+
+// BoundDistanceSquared returns the squared distance between the location and the bound on the plane, 0 if it's within the bound.
+func (d *D2) BoundDistanceSquared(bound bounds.Bound, location geo.Vec3Int) float64 {
+	du := axisDistance(location[d.axes[0]], bound.Min[d.axes[0]], bound.Max[d.axes[0]])
+	dv := axisDistance(location[d.axes[1]], bound.Min[d.axes[1]], bound.Max[d.axes[1]])
+	return du*du + dv*dv
+}
+
+// DistanceSquared returns the squared distance between two locations on the plane.
+func (d *D2) DistanceSquared(p1, p2 geo.Vec3Int) float64 {
+	du := float64(p1[d.axes[0]]) - float64(p2[d.axes[0]])
+	dv := float64(p1[d.axes[1]]) - float64(p2[d.axes[1]])
+	return du*du + dv*dv
+}
+
+// Plane returns the plane of the node.
+func (d *D2) Plane() consts.Plane {
+	return d.plane
 }
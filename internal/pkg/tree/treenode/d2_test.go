@@ -78,3 +78,25 @@ func TestD2_Intersects(t *testing.T) {
 	assert.True(t, flag)
 	assert.False(t, d2.Intersects(d2.GetChild(0), bounds.NewBound(geo.NewVec3Int(6, 0, 6), geo.NewVec3Int(8, 0, 8))))
 }
+
+func TestChildIndexMatchesTheFirstChildContaining(t *testing.T) {
+	for _, plane := range []consts.Plane{consts.PlaneXZ, consts.PlaneXY} {
+		parent, _ := NewTreeNode(consts.Dim2, nil, bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10)), 0, 0, 4, 10)
+		parent.SetPlane(plane)
+		d2 := parent.Children()
+		d2.Divide(parent, 1)
+		for u := int32(0); u <= 10; u++ {
+			for v := int32(0); v <= 10; v++ {
+				location := geo.NewVec3Int(0, 0, 0)
+				location[plane.Axes()[0]], location[plane.Axes()[1]] = u, v
+				first := -1
+				for i := 0; i < d2.ChildrenCount() && first < 0; i++ {
+					if d2.GetChild(i).Contains(mocks.CreateMockSpatial(1, location.X(), location.Y(), location.Z())) {
+						first = i
+					}
+				}
+				assert.Equal(t, first, d2.ChildIndex(parent, location), location)
+			}
+		}
+	}
+}
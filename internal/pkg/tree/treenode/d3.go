@@ -12,6 +12,8 @@ const (
 	childrenCountD3 = 8
 )
 
+var axesD3 = []int{0, 1, 2}
+
 // D3 .
 type D3 struct {
 	children [childrenCountD3]*TreeNode
@@ -85,6 +87,16 @@ func (d *D3) GetChild(index int) *TreeNode {
 	return d.children[index]
 }
 
+// SetChild replace the child at the given index.
+func (d *D3) SetChild(index int, child *TreeNode) {
+	d.children[index] = child
+}
+
+// Axes return the indexes of x, y and z.
+func (d *D3) Axes() []int {
+	return axesD3
+}
+
 // Clear the children.
 func (d *D3) Clear() {
 	d.children = [childrenCountD3]*TreeNode{}
@@ -98,20 +110,67 @@ func (d *D3) Clear() {
 // Returns:
 // - true if the entity is within the bounds, false otherwise.
 func (d *D3) Contains(n *TreeNode, spatial siface.ISpatial) bool {
-	if n.bound.Min.X() <= spatial.GetLocation().X() && spatial.GetLocation().X() <= n.bound.Max.X() &&
-		n.bound.Min.Y() <= spatial.GetLocation().Y() && spatial.GetLocation().Y() <= n.bound.Max.Y() &&
-		n.bound.Min.Z() <= spatial.GetLocation().Z() && spatial.GetLocation().Z() <= n.bound.Max.Z() {
-		return true
+	return d.ContainsLocation(n, spatial.GetLocation())
+}
+
+// ContainsLocation checks if the location is within the bounds of the node.
+func (d *D3) ContainsLocation(n *TreeNode, location geo.Vec3Int) bool {
+	return n.bound.Contains(location)
+}
+
+// ChildIndex returns the index of the first child containing the location, see the layout in Divide,
+// a location on the center belongs to the child of the smaller index.
+func (d *D3) ChildIndex(n *TreeNode, location geo.Vec3Int) int {
+	index := 0
+	if location.X() > n.bound.Center.X() {
+		index |= 4
 	}
-	return false
+	if location.Y() > n.bound.Center.Y() {
+		index |= 2
+	}
+	if location.Z() > n.bound.Center.Z() {
+		index |= 1
+	}
+	return index
 }
 
-// Intersects checks if the bound intersects with the node.
+// Intersects checks if the bound intersects with the node, the loose bound of the node if the tree is loose.
 func (d *D3) Intersects(n *TreeNode, bound bounds.Bound) bool {
-	if n.bound.Min.X() <= bound.Max.X() && bound.Min.X() <= n.bound.Max.X() &&
-		n.bound.Min.Y() <= bound.Max.Y() && bound.Min.Y() <= n.bound.Max.Y() &&
-		n.bound.Min.Z() <= bound.Max.Z() && bound.Min.Z() <= n.bound.Max.Z() {
-		return true
-	}
-	return false
+	return n.looseBound.Intersects(bound)
+}
+
+// IntersectsBound checks if the bounds intersect.
+func (d *D3) IntersectsBound(a, b bounds.Bound) bool {
+	return a.Intersects(b)
+}
+
+// ContainsBound checks if the inner bound is within the outer bound.
+func (d *D3) ContainsBound(outer, inner bounds.Bound) bool {
+	return outer.Encloses(inner)
+}
+
+// InBound checks if the location is within the bound.
+func (d *D3) InBound(bound bounds.Bound, location geo.Vec3Int) bool {
+	return bound.Contains(location)
+}
+
+// MinDistanceSquared returns the squared distance between the location and the node, the loose bound of the node if the tree is loose.
+func (d *D3) MinDistanceSquared(n *TreeNode, location geo.Vec3Int) float64 {
+	return d.BoundDistanceSquared(n.looseBound, location)
+}
+
+// BoundDistanceSquared returns the squared distance between the location and the bound, 0 if it's within the bound.
+func (d *D3) BoundDistanceSquared(bound bounds.Bound, location geo.Vec3Int) float64 {
+	dx := axisDistance(location.X(), bound.Min.X(), bound.Max.X())
+	dy := axisDistance(location.Y(), bound.Min.Y(), bound.Max.Y())
+	dz := axisDistance(location.Z(), bound.Min.Z(), bound.Max.Z())
+	return dx*dx + dy*dy + dz*dz
+}
+
+// DistanceSquared returns the squared distance between two locations.
+func (d *D3) DistanceSquared(p1, p2 geo.Vec3Int) float64 {
+	dx := float64(p1.X()) - float64(p2.X())
+	dy := float64(p1.Y()) - float64(p2.Y())
+	dz := float64(p1.Z()) - float64(p2.Z())
+	return dx*dx + dy*dy + dz*dz
 }
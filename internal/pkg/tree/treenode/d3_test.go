@@ -92,3 +92,23 @@ func TestD3_Intersects(t *testing.T) {
 	flag = d3.Intersects(d3.GetChild(0), bounds.NewBound(geo.NewVec3Int(6, 6, 6), geo.NewVec3Int(10, 10, 10)))
 	assert.False(t, flag)
 }
+
+func TestD3ChildIndexMatchesTheFirstChildContaining(t *testing.T) {
+	parent, _ := NewTreeNode(consts.Dim3, nil, bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10)), 0, 0, 4, 10)
+	d3 := parent.Children()
+	d3.Divide(parent, 1)
+	for x := int32(0); x <= 10; x++ {
+		for y := int32(0); y <= 10; y++ {
+			for z := int32(0); z <= 10; z++ {
+				location := geo.NewVec3Int(x, y, z)
+				first := -1
+				for i := 0; i < d3.ChildrenCount() && first < 0; i++ {
+					if d3.GetChild(i).Bound().Contains(location) {
+						first = i
+					}
+				}
+				assert.Equal(t, first, d3.ChildIndex(parent, location), location)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package treenode
 
 import (
 	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
 )
 
@@ -10,7 +11,21 @@ type IDimensionNode interface {
 	Divide(parent *TreeNode, depth int)
 	ChildrenCount() int
 	GetChild(index int) *TreeNode
+	// SetChild replaces the child at the index, see Grow.
+	SetChild(index int, child *TreeNode)
+	// Axes returns the indexes of the axes the node divides.
+	Axes() []int
 	Clear()
 	Contains(n *TreeNode, spatial siface.ISpatial) bool
+	ContainsLocation(n *TreeNode, location geo.Vec3Int) bool
+	// ChildIndex returns the index of the first child containing the location, which should be within the node.
+	ChildIndex(n *TreeNode, location geo.Vec3Int) int
 	Intersects(n *TreeNode, bound bounds.Bound) bool
+	IntersectsBound(a, b bounds.Bound) bool
+	ContainsBound(outer, inner bounds.Bound) bool
+	InBound(bound bounds.Bound, location geo.Vec3Int) bool
+	MinDistanceSquared(n *TreeNode, location geo.Vec3Int) float64
+	BoundDistanceSquared(bound bounds.Bound, location geo.Vec3Int) float64
+	// DistanceSquared is the metric of the dimension, a 2D node measures on its plane only.
+	DistanceSquared(p1, p2 geo.Vec3Int) float64
 }
@@ -0,0 +1,97 @@
+// Package treenode .
+package treenode
+
+import (
+	"container/heap"
+	"github.com/cozmo-zh/zearches/pkg/filter"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// candidate is a node or an entity waiting in the search queue.
+type candidate struct {
+	node   *TreeNode
+	entity siface.ISpatial
+	dist   float64 // squared distance to the search center
+}
+
+// candidateQueue is a min-heap of candidates ordered by distance.
+type candidateQueue []candidate
+
+func (q candidateQueue) Len() int           { return len(q) }
+func (q candidateQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q candidateQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *candidateQueue) Push(x any) {
+	*q = append(*q, x.(candidate))
+}
+
+func (q *candidateQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// FindNearest finds the k entities nearest to a center point, sorted by distance.
+//
+// The tree is traversed best-first: nodes are visited in order of the distance
+// between the center and their bounds, so only the nodes that may hold
+// one of the k nearest entities are opened.
+//
+// Parameters:
+// - center: The center point to search around.
+// - k: The maximum number of entities to return.
+// - maxDistance: The maximum distance of the entities, no limit if it's not positive.
+// - filters: Optional filters to apply to the entities.
+//
+// Returns:
+// - The entities sorted by ascending distance.
+func (n *TreeNode) FindNearest(center geo.Vec3Int, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	if k <= 0 {
+		return ret
+	}
+	within := func(dist float64) bool {
+		return maxDistance <= 0 || dist <= float64(maxDistance)*float64(maxDistance)
+	}
+	// the node may hold the entities out of its bound, see Put, its own entities are measured anyway.
+	// the nodes are measured from the center clamped into the node, as the ones on its edge may hold the entities
+	// clamped into it, see PutNearest, which are no nearer to the clamped center than to the center.
+	reach := n.clamp(center)
+	q := &candidateQueue{{node: n}}
+	for q.Len() > 0 && len(ret) < k {
+		c := heap.Pop(q).(candidate)
+		if c.entity != nil {
+			ret = append(ret, c.entity)
+			continue
+		}
+		if !within(c.dist) {
+			// every remaining candidate is even farther.
+			break
+		}
+		for e := c.node.entityList.Front(); e != nil; e = e.Next() {
+			spatial := e.Value.(siface.ISpatial)
+			if dist := n.distanceSquared(spatial, center); within(dist) && filter.Match(spatial, filters...) {
+				heap.Push(q, candidate{entity: spatial, dist: dist})
+			}
+		}
+		for i := 0; i < c.node.children.ChildrenCount(); i++ {
+			if child := c.node.children.GetChild(i); child != nil {
+				heap.Push(q, candidate{node: child, dist: child.children.MinDistanceSquared(child, reach)})
+			}
+		}
+	}
+	return ret
+}
+
+// axisDistance returns the distance between v and the range [min, max] on one axis.
+func axisDistance(v, min, max int32) float64 {
+	if v < min {
+		return float64(min) - float64(v)
+	}
+	if v > max {
+		return float64(v) - float64(max)
+	}
+	return 0
+}
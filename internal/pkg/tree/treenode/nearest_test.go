@@ -0,0 +1,65 @@
+package treenode
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestFindNearestMatchesBruteForce(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 5, 2)
+	r := rand.New(rand.NewSource(1))
+	spatials := make([]siface.ISpatial, 0)
+	for i := 0; i < 200; i++ {
+		spatial := mocks.CreateMockSpatial(int64(i), r.Int31n(101), r.Int31n(101), r.Int31n(101))
+		spatials = append(spatials, spatial)
+		node.Add(spatial)
+	}
+	center := geo.NewVec3Int(40, 60, 20)
+	distanceSquared := NewD3().DistanceSquared
+	sort.SliceStable(spatials, func(i, j int) bool {
+		return distanceSquared(spatials[i].GetLocation(), center) < distanceSquared(spatials[j].GetLocation(), center)
+	})
+
+	nearest := node.FindNearest(center, 10, 0)
+	assert.Len(t, nearest, 10)
+	for i, spatial := range nearest {
+		assert.Equal(t, distanceSquared(spatials[i].GetLocation(), center), distanceSquared(spatial.GetLocation(), center))
+	}
+}
+
+func TestFindNearestWithMaxDistanceAndFilters(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+	spatial1 := mocks.CreateMockSpatial(1, 1, 1, 1)
+	spatial2 := mocks.CreateMockSpatial(2, 2, 2, 2)
+	spatial3 := mocks.CreateMockSpatial(3, 9, 9, 9)
+	node.Add(spatial1)
+	node.Add(spatial2)
+	node.Add(spatial3)
+
+	center := geo.NewVec3Int(0, 0, 0)
+	assert.Equal(t, []siface.ISpatial{spatial1, spatial2, spatial3}, node.FindNearest(center, 5, 0))
+	assert.Equal(t, []siface.ISpatial{spatial1, spatial2}, node.FindNearest(center, 5, 4))
+	assert.Equal(t, []siface.ISpatial{spatial2}, node.FindNearest(center, 1, 0, func(entity siface.ISpatial) bool {
+		return entity.GetID() != 1
+	}))
+	assert.Empty(t, node.FindNearest(center, 0, 0))
+}
+
+func TestFindNearestD2IgnoresY(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 0, 10))
+	node, _ := NewTreeNode(consts.Dim2, nil, b, 0, 0, 3, 1)
+	spatial1 := mocks.CreateMockSpatial(1, 1, 50, 1)
+	spatial2 := mocks.CreateMockSpatial(2, 9, 50, 9)
+	node.Add(spatial1)
+	node.Add(spatial2)
+	assert.Equal(t, []siface.ISpatial{spatial2, spatial1}, node.FindNearest(geo.NewVec3Int(8, 50, 8), 2, 0))
+}
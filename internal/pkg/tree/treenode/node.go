@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"github.com/cozmo-zh/zearches/consts"
 	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/filter"
 	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
-	"github.com/cozmo-zh/zearches/util"
+	"iter"
+	"math"
+	"slices"
 )
 
 // TreeNode is a node in the tree.
@@ -24,6 +27,9 @@ type TreeNode struct {
 	entityIndex map[int64]*list.Element // Map of entity IDs to their list elements.
 	parent      *TreeNode               // Parent node.
 	children    IDimensionNode
+	leafIndex   map[int64]*TreeNode // Map of entity IDs to the leaf holding them, shared by the whole tree.
+	loose       float64             // The loose factor of the tree, 0 if the entities are indexed by their locations only.
+	looseBound  bounds.Bound        // The bound widened by the loose factor, the bounds of the entities held are within it.
 }
 
 // NewTreeNode creates a new tree node.
@@ -39,7 +45,13 @@ func NewTreeNode(dim consts.Dim, parent *TreeNode, bound bounds.Bound, index, de
 	var children IDimensionNode
 	switch dim {
 	case consts.Dim2:
+		// the children lie on the plane of the parent.
 		children = NewD2()
+		if parent != nil {
+			if d, ok := parent.children.(*D2); ok {
+				children = NewD2WithPlane(d.Plane())
+			}
+		}
 	case consts.Dim3:
 		children = NewD3()
 	default:
@@ -51,6 +63,11 @@ func NewTreeNode(dim consts.Dim, parent *TreeNode, bound bounds.Bound, index, de
 	if capacity < 1 {
 		return nil, fmt.Errorf("capacity should be greater than 0")
 	}
+	var leafIndex map[int64]*TreeNode
+	var loose float64
+	if parent != nil {
+		leafIndex, loose = parent.leafIndex, parent.loose
+	}
 	return &TreeNode{
 		parent:      parent,
 		depth:       depth,
@@ -61,6 +78,9 @@ func NewTreeNode(dim consts.Dim, parent *TreeNode, bound bounds.Bound, index, de
 		entityIndex: make(map[int64]*list.Element),
 		children:    children,
 		index:       index,
+		leafIndex:   leafIndex,
+		loose:       loose,
+		looseBound:  looseBoundOf(bound, loose),
 	}, nil
 }
 
@@ -78,6 +98,9 @@ func (n *TreeNode) Add(spatial siface.ISpatial) bool {
 	add := func(_n *TreeNode, spatial siface.ISpatial) {
 		e := _n.entityList.PushBack(spatial)
 		_n.entityIndex[spatial.GetID()] = e
+		if _n.leafIndex != nil {
+			_n.leafIndex[spatial.GetID()] = _n
+		}
 	}
 	add2Children := func(_n *TreeNode, spatial siface.ISpatial) bool {
 		for i := 0; i < n.children.ChildrenCount(); i++ {
@@ -93,18 +116,110 @@ func (n *TreeNode) Add(spatial siface.ISpatial) bool {
 			return true
 		} else {
 			if n.DivideIf() {
-				add2Children(n, spatial)
+				if !add2Children(n, spatial) {
+					// the bound of the entity straddles the children of a loose tree.
+					add(n, spatial)
+				}
 			} else {
 				add(n, spatial)
 				return true
 			}
 		}
-	} else {
-		add2Children(n, spatial)
+	} else if !add2Children(n, spatial) {
+		add(n, spatial)
 	}
 	return true
 }
 
+// AddBatch adds the spatial entities to the node top-down in one pass,
+// each node is divided at most once instead of splitting and moving its entities repeatedly like Add.
+// the entities out of the bounds of the node are skipped.
+//
+// Returns:
+// - the number of entities added.
+func (n *TreeNode) AddBatch(spatials []siface.ISpatial) int {
+	in := make([]siface.ISpatial, 0, len(spatials))
+	for _, spatial := range spatials {
+		if n.Contains(spatial) {
+			in = append(in, spatial)
+		}
+	}
+	n.addBatch(in, make([]siface.ISpatial, len(in)), make([]uint8, len(in)))
+	return len(in)
+}
+
+// addBatch adds the spatial entities within the node, buf and index are scratch buffers of the same length.
+// the entities are partitioned among the children by a counting sort into buf, which is split into the batches of
+// the children, and the spatials becomes their scratch buffer in turn, so no allocation is needed at each level.
+func (n *TreeNode) addBatch(spatials, buf []siface.ISpatial, index []uint8) {
+	if len(spatials) == 0 {
+		return
+	}
+	if n.IsLeaf() {
+		if n.entityList.Len()+len(spatials) <= n.capacity || n.depth >= n.maxDepth-1 {
+			for _, spatial := range spatials {
+				n.Put(spatial)
+			}
+			return
+		}
+		n.children.Divide(n, n.depth+1)
+		if n.entityList.Len() > 0 {
+			// move the entities of the leaf down with the batch.
+			moved := make([]siface.ISpatial, 0, len(spatials)+n.entityList.Len())
+			moved = append(moved, spatials...)
+			for e := n.entityList.Front(); e != nil; e = e.Next() {
+				moved = append(moved, e.Value.(siface.ISpatial))
+			}
+			n.Clear()
+			n.addBatch(moved, make([]siface.ISpatial, len(moved)), make([]uint8, len(moved)))
+			return
+		}
+	}
+	var offsets [childrenCountD3 + 1]int
+	for i, spatial := range spatials {
+		child := n.childIndex(spatial)
+		if child < 0 {
+			n.Put(spatial)
+			index[i] = stay
+			continue
+		}
+		index[i] = uint8(child)
+		offsets[index[i]+1]++
+	}
+	count := n.children.ChildrenCount()
+	for i := 0; i < count; i++ {
+		offsets[i+1] += offsets[i]
+	}
+	next := offsets
+	for i, spatial := range spatials {
+		if index[i] != stay {
+			buf[next[index[i]]] = spatial
+			next[index[i]]++
+		}
+	}
+	for i := 0; i < count; i++ {
+		from, to := offsets[i], offsets[i+1]
+		n.children.GetChild(i).addBatch(buf[from:to], spatials[from:to], index[from:to])
+	}
+}
+
+// stay marks the entities kept by the node in addBatch.
+const stay = math.MaxUint8
+
+// childIndex returns the index of the first child containing the spatial entity, -1 if none does.
+// only the location matters unless the tree is loose, see ChildIndex.
+func (n *TreeNode) childIndex(spatial siface.ISpatial) int {
+	if n.loose == 0 {
+		return n.children.ChildIndex(n, spatial.GetLocation())
+	}
+	for i := 0; i < n.children.ChildrenCount(); i++ {
+		if n.children.GetChild(i).Contains(spatial) {
+			return i
+		}
+	}
+	return -1
+}
+
 // Remove removes a spatial entity from the node by its ID.
 //
 // Parameters:
@@ -114,15 +229,18 @@ func (n *TreeNode) Add(spatial siface.ISpatial) bool {
 // Returns:
 // - true if the entity was removed successfully, false otherwise.
 func (n *TreeNode) Remove(spatialId int64, merge ...bool) bool {
-	if n.IsLeaf() {
-		if e, ok := n.entityIndex[spatialId]; ok {
-			delete(n.entityIndex, spatialId)
-			n.entityList.Remove(e)
-			if len(merge) > 0 && merge[0] {
-				n.MergeIf()
-			}
-			return true
+	if e, ok := n.entityIndex[spatialId]; ok {
+		delete(n.entityIndex, spatialId)
+		if n.leafIndex != nil {
+			delete(n.leafIndex, spatialId)
+		}
+		n.entityList.Remove(e)
+		if len(merge) > 0 && merge[0] {
+			n.MergeIf()
 		}
+		return true
+	}
+	if n.IsLeaf() {
 		return false
 	} else {
 		for i := 0; i < n.children.ChildrenCount(); i++ {
@@ -134,6 +252,112 @@ func (n *TreeNode) Remove(spatialId int64, merge ...bool) bool {
 	}
 }
 
+// Relocate moves the entity out of the leaf n if it's no longer contained by n,
+// walking up to the first ancestor that contains it.
+// The entity held by an internal node of a loose tree moves down as well if it fits in a child.
+//
+// Parameters:
+// - spatial: The spatial entity with its new location.
+// - merge: Whether to merge the leaf after the entity left it.
+//
+// Returns:
+// - true if the entity was relocated successfully, false if n doesn't hold the entity or
+// its new location is outside the bounds of the tree.
+func (n *TreeNode) Relocate(spatial siface.ISpatial, merge ...bool) bool {
+	e, ok := n.entityIndex[spatial.GetID()]
+	if !ok {
+		return false
+	}
+	if n.Contains(spatial) && (n.IsLeaf() || n.childIndex(spatial) < 0) {
+		e.Value = spatial
+		return true
+	}
+	ancestor := n
+	for ancestor != nil && !ancestor.Contains(spatial) {
+		ancestor = ancestor.parent
+	}
+	if ancestor == nil {
+		return false
+	}
+	delete(n.entityIndex, spatial.GetID())
+	n.entityList.Remove(e)
+	ancestor.Add(spatial)
+	if len(merge) > 0 && merge[0] {
+		n.MergeIf()
+	}
+	return true
+}
+
+// Get returns the entity with the given ID held by the node itself.
+func (n *TreeNode) Get(spatialId int64) (siface.ISpatial, bool) {
+	if e, ok := n.entityIndex[spatialId]; ok {
+		return e.Value.(siface.ISpatial), true
+	}
+	return nil, false
+}
+
+// SetLeafIndex sets the map of entity IDs to leaves shared by the whole tree.
+// It should be called on the root before any entity is added.
+func (n *TreeNode) SetLeafIndex(leafIndex map[int64]*TreeNode) {
+	n.leafIndex = leafIndex
+}
+
+// SetPlane sets the plane of a 2D node, the descendants inherit it.
+// It should be called on the root before any entity is added, it does nothing to a 3D node.
+func (n *TreeNode) SetPlane(plane consts.Plane) {
+	if _, ok := n.children.(*D2); ok {
+		n.children = NewD2WithPlane(plane)
+	}
+}
+
+// SetLoose sets the loose factor of the tree, the descendants inherit it.
+// It should be called on the root before any entity is added.
+//
+// The node of a loose tree holds the entities whose location is within its bound and whose bound is within
+// its bound widened by (k-1)/2 of its size on each side, an entity fitting no child is held by the node itself.
+// The entities are measured by their bounds instead of their locations by the queries.
+// A factor of 2 is usual, a factor of 1 keeps the entities straddling the children in the parent.
+//
+// Parameters:
+// - k: the loose factor, at least 1, 0 to index the entities by their locations only.
+func (n *TreeNode) SetLoose(k float64) error {
+	if k != 0 && !(k >= 1) {
+		return fmt.Errorf("loose factor should be at least 1")
+	}
+	n.loose = k
+	n.looseBound = looseBoundOf(n.bound, k)
+	return nil
+}
+
+// Loose checks if the tree is loose, see SetLoose.
+func (n *TreeNode) Loose() bool {
+	return n.loose > 0
+}
+
+// LooseFactor returns the loose factor of the tree, 0 if it's not loose.
+func (n *TreeNode) LooseFactor() float64 {
+	return n.loose
+}
+
+// LooseBound returns the bound widened by the loose factor, the bound itself if the tree is not loose.
+func (n *TreeNode) LooseBound() bounds.Bound {
+	return n.looseBound
+}
+
+// looseBoundOf returns the bound widened by (k-1)/2 of its size on each side, the bound itself if k is at most 1.
+func looseBoundOf(bound bounds.Bound, k float64) bounds.Bound {
+	if k <= 1 {
+		return bound
+	}
+	bMin, bMax := geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(0, 0, 0)
+	for i := range bMin {
+		pad := math.Ceil((k - 1) / 2 * (float64(bound.Max[i]) - float64(bound.Min[i])))
+		bMin[i] = int32(max(math.MinInt32, float64(bound.Min[i])-pad))
+		bMax[i] = int32(min(math.MaxInt32, float64(bound.Max[i])+pad))
+	}
+	return bounds.NewBound(bMin, bMax)
+}
+
 // GetEntityList returns the list of entities in the node.
 //
 // Returns:
@@ -166,23 +390,138 @@ func (n *TreeNode) DivideIf() bool {
 		return false
 	}
 	n.children.Divide(n, n.depth+1)
-	// Move entities to children.
-	for e := n.entityList.Front(); e != nil; e = e.Next() {
+	// Move entities to children, the ones straddling the children of a loose tree stay.
+	for e := n.entityList.Front(); e != nil; {
+		next := e.Next()
 		spatial := e.Value.(siface.ISpatial)
 		for i := 0; i < n.children.ChildrenCount(); i++ {
 			child := n.children.GetChild(i)
 			if child.Contains(spatial) {
+				delete(n.entityIndex, spatial.GetID())
+				n.entityList.Remove(e)
 				child.Add(spatial)
 				break
 			}
 		}
+		e = next
 	}
-	// Clear the entity list.
-	n.Clear()
 	return true
 }
 
-// Contains checks if the spatial entity is within the bounds of the node.
+// Split divides the node into children whatever its entities, they stay in the node.
+// it's used to restore the structure of a tree, see Put.
+func (n *TreeNode) Split() {
+	if n.IsLeaf() {
+		n.children.Divide(n, n.depth+1)
+	}
+}
+
+// Put adds the spatial entity to the node itself, ignoring the capacity and the children.
+// it's used to restore the entities of a tree to the nodes they were in,
+// and by the root to hold the entities out of its bound, which every query visits.
+func (n *TreeNode) Put(spatial siface.ISpatial) {
+	e := n.entityList.PushBack(spatial)
+	n.entityIndex[spatial.GetID()] = e
+	if n.leafIndex != nil {
+		n.leafIndex[spatial.GetID()] = n
+	}
+}
+
+// PutNearest puts the spatial entity out of the bound of the node into the deepest node containing
+// the point of the bound nearest to its location, whose loose bound contains its bound clamped as well if the tree is loose.
+// The entity keeps its location, the queries find it as they clamp their bounds into the bound of the root, see visitEntities.
+func (n *TreeNode) PutNearest(spatial siface.ISpatial) {
+	location := n.clamp(spatial.GetLocation())
+	bound := spatial.GetBound()
+	node := n
+	for !node.IsLeaf() {
+		child := node.children.GetChild(node.children.ChildIndex(node, location))
+		if n.loose > 0 && hasBound(bound) && !n.children.ContainsBound(child.looseBound, bounds.NewBound(n.clamp(bound.Min), n.clamp(bound.Max))) {
+			break
+		}
+		node = child
+	}
+	node.Put(spatial)
+}
+
+// clamp returns the location clamped into the bound of the node.
+func (n *TreeNode) clamp(location geo.Vec3Int) geo.Vec3Int {
+	ret := geo.NewVec3Int(0, 0, 0)
+	for i := range ret {
+		ret[i] = min(max(location[i], n.bound.Min[i]), n.bound.Max[i])
+	}
+	return ret
+}
+
+// Grow returns a new root whose bound doubles the bound of the node toward the location on each axis,
+// the node becomes one of its children, so the tree is one level deeper and the nodes keep their sizes.
+// The node should be the root.
+//
+// Returns:
+// - the new root, an error if the node is not the root or its bound can't double within int32.
+func (n *TreeNode) Grow(toward geo.Vec3Int) (*TreeNode, error) {
+	if n.parent != nil {
+		return nil, fmt.Errorf("only the root can grow")
+	}
+	bMin, bMax := slices.Clone(n.bound.Min), slices.Clone(n.bound.Max)
+	for _, axis := range n.children.Axes() {
+		lo, hi := int64(n.bound.Min[axis]), int64(n.bound.Max[axis])
+		size := hi - lo
+		if toward[axis] < n.bound.Center[axis] {
+			lo -= size
+		} else {
+			hi += size
+		}
+		if size <= 0 || lo < math.MinInt32 || hi > math.MaxInt32 || lo+hi < math.MinInt32 || lo+hi > math.MaxInt32 {
+			return nil, fmt.Errorf("bound %v can't grow toward %v", n.bound, toward)
+		}
+		bMin[axis], bMax[axis] = int32(lo), int32(hi)
+	}
+	dim := consts.Dim3
+	if _, ok := n.children.(*D2); ok {
+		dim = consts.Dim2
+	}
+	root, err := NewTreeNode(dim, nil, bounds.NewBound(bMin, bMax), 0, 0, n.maxDepth+1, n.capacity)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := n.children.(*D2); ok {
+		root.SetPlane(d.Plane())
+	}
+	root.SetLeafIndex(n.leafIndex)
+	if err = root.SetLoose(n.loose); err != nil {
+		return nil, err
+	}
+	root.children.Divide(root, 1)
+	for i := 0; i < root.children.ChildrenCount(); i++ {
+		child := root.children.GetChild(i)
+		if root.children.ContainsBound(child.bound, n.bound) && root.children.ContainsBound(n.bound, child.bound) {
+			n.parent, n.index = root, i
+			root.children.SetChild(i, n)
+			break
+		}
+	}
+	// the entities straddling the edge of the old root move up to the new one.
+	for e := n.entityList.Front(); e != nil; {
+		next := e.Next()
+		if spatial := e.Value.(siface.ISpatial); !n.Contains(spatial) {
+			delete(n.entityIndex, spatial.GetID())
+			n.entityList.Remove(e)
+			root.Put(spatial)
+		}
+		e = next
+	}
+	n.Range(func(node *TreeNode) bool {
+		node.depth++
+		node.maxDepth++
+		return true
+	})
+	return root, nil
+}
+
+// Contains checks if the spatial entity is within the bounds of the node,
+// and its bound is within the loose bound of the node if the tree is loose and the node is not the root.
+// The root contains any entity located within it, it holds the ones whose bound straddles its edge.
 //
 // Parameters:
 // - spatial: The spatial entity to check.
@@ -190,7 +529,33 @@ func (n *TreeNode) DivideIf() bool {
 // Returns:
 // - true if the entity is within the bounds, false otherwise.
 func (n *TreeNode) Contains(spatial siface.ISpatial) bool {
-	return n.children.Contains(n, spatial)
+	if !n.children.Contains(n, spatial) {
+		return false
+	}
+	bound := spatial.GetBound()
+	return n.loose == 0 || n.parent == nil || !hasBound(bound) || n.children.ContainsBound(n.looseBound, bound)
+}
+
+// hasBound checks if the bound of an entity is set, an entity without bound is a point at its location.
+func hasBound(bound bounds.Bound) bool {
+	return len(bound.Min) == 3 && len(bound.Max) == 3
+}
+
+// distanceSquared returns the squared distance between the entity and the location,
+// measured from the bound of the entity if the tree is loose.
+func (n *TreeNode) distanceSquared(spatial siface.ISpatial, location geo.Vec3Int) float64 {
+	if bound := spatial.GetBound(); n.loose > 0 && hasBound(bound) {
+		return n.children.BoundDistanceSquared(bound, location)
+	}
+	return n.children.DistanceSquared(spatial.GetLocation(), location)
+}
+
+// inBound checks if the entity is within the bound, its bound intersects the bound if the tree is loose.
+func (n *TreeNode) inBound(bound bounds.Bound, spatial siface.ISpatial) bool {
+	if b := spatial.GetBound(); n.loose > 0 && hasBound(b) {
+		return n.children.IntersectsBound(bound, b)
+	}
+	return n.children.InBound(bound, spatial.GetLocation())
 }
 
 // Intersects checks if the bound intersects with the node.
@@ -214,8 +579,8 @@ func (n *TreeNode) MergeIf() bool {
 	if !n.IsLeaf() || n.parent == nil {
 		return false
 	}
-	// check other siblings
-	count := 0
+	// check other siblings, and the entities held by the parent of a loose tree.
+	count := n.parent.Size()
 	for i := 0; i < n.parent.Children().ChildrenCount(); i++ {
 		child := n.parent.Children().GetChild(i)
 		if !child.IsLeaf() {
@@ -238,56 +603,108 @@ func (n *TreeNode) MergeIf() bool {
 	}
 	n.parent.ClearChildren()
 	for _, spatial := range add {
-		n.parent.Add(spatial)
+		if !n.parent.Add(spatial) {
+			// out of the bound of the tree, see PutNearest.
+			n.parent.Put(spatial)
+		}
 	}
 	return true
 }
 
-// FindEntities finds entities within a radius of a center point.
+// FindEntities finds entities within a radius of a center point, measured by the metric of the dimension.
 func (n *TreeNode) FindEntities(center geo.Vec3Int, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	n.VisitEntities(center, radius, func(spatial siface.ISpatial) bool {
+		ret = append(ret, spatial)
+		return true
+	}, filters...)
+	return ret
+}
+
+// VisitEntities calls visit for each entity within a radius of a center point, measured by the metric of the dimension,
+// until visit returns false.
+func (n *TreeNode) VisitEntities(center geo.Vec3Int, radius float32, visit func(spatial siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
 	// build a cube bound for the search
 	// the cube is a bounding box of the sphere
-	cMin := geo.NewVec3Int(center.X()-int32(radius), center.Y()-int32(radius), center.Z()-int32(radius))
-	cMax := geo.NewVec3Int(center.X()+int32(radius), center.Y()+int32(radius), center.Z()+int32(radius))
-	cBound := bounds.NewBound(cMin, cMax)
-	return n.FindEntitiesInBound(cBound, radius, filters...)
+	q := queryPool.Get().(*query)
+	defer queryPool.Put(q)
+	r := int32(radius)
+	q.center = [3]int32{center[0], center[1], center[2]}
+	q.min = [3]int32{center[0] - r, center[1] - r, center[2] - r}
+	q.max = [3]int32{center[0] + r, center[1] + r, center[2] + r}
+	c := geo.Vec3Int(q.center[:])
+	within := func(spatial siface.ISpatial) bool {
+		return n.distanceSquared(spatial, c) <= float64(radius)*float64(radius)
+	}
+	n.visitEntities(q.bound(), within, filters, visit)
 }
 
-// FindEntitiesInBound finds entities within a bound.
-func (n *TreeNode) FindEntitiesInBound(bound bounds.Bound, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
-	// search entities
-	ret := make([]siface.ISpatial, 0)
-	if n.Intersects(bound) {
-		if n.IsLeaf() {
-			for e := n.entityList.Front(); e != nil; e = e.Next() {
-				spatial := e.Value.(siface.ISpatial)
-				if util.WithinDistance3D(spatial.GetLocation().ToFloat32(), bound.Center.ToFloat32(), radius) {
-					if len(filters) == 0 {
-						ret = append(ret, spatial)
-					} else {
-						for _, filter := range filters {
-							if filter(spatial) {
-								ret = append(ret, spatial)
-								break
-							}
-						}
-					}
-				}
-			}
-		} else {
-			// check children
-			for i := 0; i < n.children.ChildrenCount(); i++ {
-				child := n.children.GetChild(i)
-				if child == nil {
-					continue
-				}
-				ret = append(ret, child.FindEntitiesInBound(bound, radius, filters...)...)
-			}
-		}
+// FindEntitiesInBound finds entities whose location is within a bound, whose bound intersects it if the tree is loose.
+func (n *TreeNode) FindEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	inBound := func(spatial siface.ISpatial) bool {
+		return n.inBound(bound, spatial)
 	}
+	return n.findEntities(bound, inBound, filters, make([]siface.ISpatial, 0))
+}
+
+// FindEntitiesWith finds entities accepted by accept within the nodes intersecting the bound.
+func (n *TreeNode) FindEntitiesWith(bound bounds.Bound, accept func(spatial siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	return n.findEntities(bound, accept, filters, make([]siface.ISpatial, 0))
+}
+
+// VisitEntitiesWith calls visit for each entity accepted by accept within the nodes intersecting the bound,
+// until visit returns false.
+func (n *TreeNode) VisitEntitiesWith(bound bounds.Bound, accept func(spatial siface.ISpatial) bool, visit func(spatial siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	n.visitEntities(bound, accept, filters, visit)
+}
+
+// findEntities appends the entities accepted within the nodes intersecting the bound to ret.
+func (n *TreeNode) findEntities(bound bounds.Bound, accept func(spatial siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool, ret []siface.ISpatial) []siface.ISpatial {
+	n.visitEntities(bound, accept, filters, func(spatial siface.ISpatial) bool {
+		ret = append(ret, spatial)
+		return true
+	})
 	return ret
 }
 
+// visitEntities calls visit for each entity accepted within the nodes intersecting the bound.
+// Returns false if visit returns false, the search stops.
+func (n *TreeNode) visitEntities(bound bounds.Bound, accept func(spatial siface.ISpatial) bool, filters []func(entity siface.ISpatial) bool, visit func(spatial siface.ISpatial) bool) bool {
+	if n.parent == nil && !n.bound.Encloses(bound) {
+		// the entities clamped into the tree are held by the nodes on its edge, see PutNearest,
+		// the bound clamped into the root reaches these nodes whenever it reaches their entities.
+		q := queryPool.Get().(*query)
+		defer queryPool.Put(q)
+		for i := range q.min {
+			q.min[i] = min(max(bound.Min[i], n.bound.Min[i]), n.bound.Max[i])
+			q.max[i] = min(max(bound.Max[i], n.bound.Min[i]), n.bound.Max[i])
+		}
+		bound = q.bound()
+	}
+	intersects := n.Intersects(bound)
+	if !intersects && n.parent != nil {
+		return true
+	}
+	// an internal node holds entities only if the tree is loose or they are out of the bound of the tree,
+	// the root may hold the ones out of its bound, see Put.
+	for e := n.entityList.Front(); e != nil; e = e.Next() {
+		spatial := e.Value.(siface.ISpatial)
+		if accept(spatial) && filter.Match(spatial, filters...) && !visit(spatial) {
+			return false
+		}
+	}
+	if !intersects || n.IsLeaf() {
+		return true
+	}
+	// check children
+	for i := 0; i < n.children.ChildrenCount(); i++ {
+		if child := n.children.GetChild(i); child != nil && !child.visitEntities(bound, accept, filters, visit) {
+			return false
+		}
+	}
+	return true
+}
+
 // Bound returns the spatial boundaries of the node.
 func (n *TreeNode) Bound() bounds.Bound {
 	return n.bound
@@ -341,6 +758,43 @@ func (n *TreeNode) Range(f func(n *TreeNode) bool) {
 	}
 }
 
+// rangeNodes ranges the node and its descendants in depth-first order like Range,
+// but stops the whole traversal when f returns false, which it reports by returning false.
+func (n *TreeNode) rangeNodes(f func(n *TreeNode) bool) bool {
+	if !f(n) {
+		return false
+	}
+	if !n.IsLeaf() {
+		for i := 0; i < n.children.ChildrenCount(); i++ {
+			if child := n.children.GetChild(i); child != nil && !child.rangeNodes(f) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Nodes returns an iterator over the node and its descendants in depth-first order.
+func (n *TreeNode) Nodes() iter.Seq[*TreeNode] {
+	return func(yield func(n *TreeNode) bool) {
+		n.rangeNodes(yield)
+	}
+}
+
+// All returns an iterator over the entities in the node and its descendants.
+func (n *TreeNode) All() iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		n.rangeNodes(func(node *TreeNode) bool {
+			stopped := false
+			node.RangeEntities(func(entity siface.ISpatial) bool {
+				stopped = !yield(entity)
+				return !stopped
+			})
+			return !stopped
+		})
+	}
+}
+
 // RangeEntities ranges the entities in the node.
 func (n *TreeNode) RangeEntities(f func(entity siface.ISpatial) bool) {
 	for e := n.entityList.Front(); e != nil; e = e.Next() {
@@ -203,3 +203,254 @@ func TestTreeNode_Range(t *testing.T) {
 	assert.Contains(t, entities, spatial3)
 
 }
+
+func TestTreeNode_RangeStops(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+	node.Add(mocks.CreateMockSpatial(1, 1, 1, 1))
+	node.Add(mocks.CreateMockSpatial(2, 2, 2, 2))
+
+	// Range only prunes the subtree of the node f returns false for.
+	visited := 0
+	node.Range(func(n *TreeNode) bool {
+		visited++
+		return n.depth == 0
+	})
+	assert.Equal(t, 9, visited)
+
+	// the iterator stops the whole traversal when the loop breaks.
+	visited = 0
+	for range node.Nodes() {
+		if visited++; visited == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, visited)
+}
+
+func TestRelocateWithinLeaf(t *testing.T) {
+	maxDepth := 2
+	capacity := 1
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, maxDepth, capacity)
+
+	spatial1 := mocks.CreateMockSpatial(1, 1, 1, 1)
+	spatial2 := mocks.CreateMockSpatial(2, 8, 8, 8)
+	node.Add(spatial1)
+	node.Add(spatial2)
+	leaf := node.Children().GetChild(0)
+	assert.Equal(t, 1, leaf.Size())
+
+	moved := mocks.CreateMockSpatial(1, 2, 2, 2)
+	assert.True(t, leaf.Relocate(moved))
+	assert.Equal(t, 1, leaf.Size())
+	assert.Equal(t, moved, leaf.GetEntityList().Front().Value)
+}
+
+func TestRelocateAcrossLeaves(t *testing.T) {
+	maxDepth := 3
+	capacity := 1
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, maxDepth, capacity)
+
+	spatial1 := mocks.CreateMockSpatial(1, 1, 1, 1)
+	spatial2 := mocks.CreateMockSpatial(2, 8, 8, 8)
+	node.Add(spatial1)
+	node.Add(spatial2)
+
+	moved := mocks.CreateMockSpatial(1, 9, 1, 1)
+	assert.True(t, node.Children().GetChild(0).Relocate(moved))
+	assert.Equal(t, 0, node.Children().GetChild(0).Size())
+	assert.Equal(t, 1, node.Children().GetChild(4).Size())
+	entities := node.FindEntities(geo.NewVec3Int(9, 1, 1), 1)
+	assert.Equal(t, []siface.ISpatial{moved}, entities)
+
+	// out of the root bound, the tree is unchanged
+	outside := mocks.CreateMockSpatial(1, 20, 1, 1)
+	assert.False(t, node.Children().GetChild(4).Relocate(outside))
+	assert.Equal(t, 1, node.Children().GetChild(4).Size())
+
+	// not held by the leaf
+	assert.False(t, node.Children().GetChild(4).Relocate(mocks.CreateMockSpatial(2, 1, 1, 1)))
+}
+
+func TestFindEntitiesInBound(t *testing.T) {
+	maxDepth := 3
+	capacity := 1
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(10, 10, 10))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, maxDepth, capacity)
+
+	spatial1 := mocks.CreateMockSpatial(1, 2, 2, 2)
+	spatial2 := mocks.CreateMockSpatial(2, 4, 4, 4) // corner of the box, beyond the inscribed sphere
+	spatial3 := mocks.CreateMockSpatial(3, 8, 8, 8)
+	node.Add(spatial1)
+	node.Add(spatial2)
+	node.Add(spatial3)
+
+	entities := node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(4, 4, 4)))
+	assert.ElementsMatch(t, []siface.ISpatial{spatial1, spatial2}, entities)
+	entities = node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(4, 4, 4)), func(entity siface.ISpatial) bool {
+		return entity.GetID() == 2
+	})
+	assert.Equal(t, []siface.ISpatial{spatial2}, entities)
+	assert.Empty(t, node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(5, 0, 0), geo.NewVec3Int(7, 10, 10))))
+}
+
+func TestLooseStraddling(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+	assert.Nil(t, node.SetLoose(1))
+	node.SetLeafIndex(make(map[int64]*TreeNode))
+
+	node.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	node.Add(mocks.CreateMockSpatial(2, 90, 90, 90))
+	// straddles the children, kept by the root.
+	big := mocks.CreateMockSpatial(3, 45, 45, 45, bounds.NewBound(geo.NewVec3Int(40, 40, 40), geo.NewVec3Int(60, 60, 60)))
+	assert.True(t, node.Add(big))
+	assert.False(t, node.IsLeaf())
+	assert.Equal(t, []siface.ISpatial{big}, entitiesOf(node))
+	// found by its bound, its location is out of the box.
+	assert.Equal(t, []siface.ISpatial{big}, node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(55, 55, 55), geo.NewVec3Int(70, 70, 70))))
+	assert.Equal(t, []siface.ISpatial{big}, node.FindEntities(geo.NewVec3Int(65, 65, 65), 9))
+	assert.Equal(t, []siface.ISpatial{big}, node.FindNearest(geo.NewVec3Int(61, 61, 61), 1, 0))
+
+	// shrunk into the last child of the first child, which is divided.
+	shrunk := mocks.CreateMockSpatial(3, 45, 45, 45, bounds.NewBound(geo.NewVec3Int(40, 40, 40), geo.NewVec3Int(50, 50, 50)))
+	assert.True(t, node.leafIndex[3].Relocate(shrunk))
+	assert.Equal(t, 0, node.Size())
+	assert.Same(t, node.Children().GetChild(0).Children().GetChild(7), node.leafIndex[3])
+
+	assert.True(t, node.leafIndex[3].Relocate(big))
+	assert.Same(t, node, node.leafIndex[3])
+	assert.True(t, node.Remove(3))
+	assert.Equal(t, 0, node.Size())
+	// straddles the edge of the root, which holds it as its location is within.
+	edge := mocks.CreateMockSpatial(4, 95, 95, 95, bounds.NewBound(geo.NewVec3Int(90, 90, 90), geo.NewVec3Int(110, 110, 110)))
+	assert.True(t, node.Add(edge))
+	assert.Same(t, node, node.leafIndex[4])
+	assert.Equal(t, []siface.ISpatial{edge}, node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(105, 105, 105), geo.NewVec3Int(120, 120, 120))))
+	// out of the root bound.
+	assert.False(t, node.Add(mocks.CreateMockSpatial(5, 105, 95, 95, bounds.NewBound(geo.NewVec3Int(100, 90, 90), geo.NewVec3Int(110, 100, 100)))))
+}
+
+func TestLooseFactor(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 2, 1)
+	assert.NotNil(t, node.SetLoose(0.5))
+	assert.Nil(t, node.SetLoose(2))
+	assert.Equal(t, bounds.NewBound(geo.NewVec3Int(-50, -50, -50), geo.NewVec3Int(150, 150, 150)), node.LooseBound())
+
+	node.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	node.Add(mocks.CreateMockSpatial(2, 90, 90, 90))
+	// within the loose bound of the first child, whose bound holds its location.
+	big := mocks.CreateMockSpatial(3, 45, 45, 45, bounds.NewBound(geo.NewVec3Int(20, 20, 20), geo.NewVec3Int(70, 70, 70)))
+	assert.True(t, node.Add(big))
+	assert.Equal(t, 0, node.Size())
+	assert.Equal(t, bounds.NewBound(geo.NewVec3Int(-25, -25, -25), geo.NewVec3Int(75, 75, 75)), node.Children().GetChild(0).LooseBound())
+	assert.Equal(t, []siface.ISpatial{big}, node.FindEntitiesInBound(bounds.NewBound(geo.NewVec3Int(65, 65, 65), geo.NewVec3Int(80, 80, 80))))
+}
+
+func TestLooseAddBatch(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	spatials := []siface.ISpatial{
+		mocks.CreateMockSpatial(1, 10, 10, 10),
+		mocks.CreateMockSpatial(2, 90, 90, 90),
+		mocks.CreateMockSpatial(3, 45, 45, 45, bounds.NewBound(geo.NewVec3Int(40, 40, 40), geo.NewVec3Int(60, 60, 60))),
+		mocks.CreateMockSpatial(4, 20, 20, 20, bounds.NewBound(geo.NewVec3Int(15, 15, 15), geo.NewVec3Int(25, 25, 25))),
+	}
+	// the straddling one stays in the root if the tree is sticky.
+	for _, k := range []float64{1, 2} {
+		incremental, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+		batch, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+		for _, node := range []*TreeNode{incremental, batch} {
+			assert.Nil(t, node.SetLoose(k))
+		}
+		for _, spatial := range spatials {
+			incremental.Add(spatial)
+		}
+		assert.Equal(t, 4, batch.AddBatch(spatials))
+		for n := range incremental.Nodes() {
+			assert.ElementsMatch(t, entitiesOf(n), entitiesOf(nodeAt(batch, n)), k)
+		}
+	}
+}
+
+// entitiesOf returns the entities held by the node itself.
+func entitiesOf(n *TreeNode) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0)
+	n.RangeEntities(func(entity siface.ISpatial) bool {
+		ret = append(ret, entity)
+		return true
+	})
+	return ret
+}
+
+// nodeAt returns the node of the tree of root at the same place as n.
+func nodeAt(root, n *TreeNode) *TreeNode {
+	if n.Parent() == nil {
+		return root
+	}
+	parent := nodeAt(root, n.Parent())
+	if parent == nil || parent.IsLeaf() {
+		return nil
+	}
+	return parent.Children().GetChild(n.Index())
+}
+
+func TestGrow(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+	leafIndex := make(map[int64]*TreeNode)
+	node.SetLeafIndex(leafIndex)
+	node.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	node.Add(mocks.CreateMockSpatial(2, 90, 90, 90))
+
+	// toward -x, +y and +z.
+	root, err := node.Grow(geo.NewVec3Int(-50, 150, 60))
+	assert.Nil(t, err)
+	assert.Equal(t, bounds.NewBound(geo.NewVec3Int(-100, 0, 0), geo.NewVec3Int(100, 200, 200)), root.Bound())
+	assert.Same(t, node, root.Children().GetChild(4))
+	assert.Same(t, root, node.Parent())
+	assert.Equal(t, 4, node.MaxDepth())
+	assert.Equal(t, 1, node.Depth())
+	assert.Equal(t, 2, node.Children().GetChild(0).Depth())
+	assert.Same(t, node.Children().GetChild(0), leafIndex[1])
+	assert.True(t, root.Add(mocks.CreateMockSpatial(3, -50, 150, 60)))
+	assert.Equal(t, 1, root.Children().GetChild(2).Size())
+	assert.Len(t, root.FindEntities(geo.NewVec3Int(0, 0, 0), 1000), 3)
+
+	_, err = node.Grow(geo.NewVec3Int(0, 0, 0))
+	assert.NotNil(t, err)
+	huge, _ := NewTreeNode(consts.Dim3, nil, bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1<<30, 1, 1)), 0, 0, 3, 1)
+	_, err = huge.Grow(geo.NewVec3Int(1<<30+1, 0, 0))
+	assert.NotNil(t, err)
+}
+
+func TestGrowD2(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 7), geo.NewVec3Int(100, 100, 7))
+	node, _ := NewTreeNode(consts.Dim2, nil, b, 0, 0, 3, 1)
+	node.SetPlane(consts.PlaneXY)
+	root, err := node.Grow(geo.NewVec3Int(150, -1, 1000))
+	assert.Nil(t, err)
+	// z is not on the plane.
+	assert.Equal(t, bounds.NewBound(geo.NewVec3Int(0, -100, 7), geo.NewVec3Int(200, 100, 7)), root.Bound())
+	assert.Equal(t, consts.PlaneXY, root.Children().(*D2).Plane())
+	assert.Same(t, node, root.Children().GetChild(1))
+}
+
+func TestPutNearest(t *testing.T) {
+	b := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	node, _ := NewTreeNode(consts.Dim3, nil, b, 0, 0, 3, 1)
+	node.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	node.Add(mocks.CreateMockSpatial(2, 90, 90, 90))
+	outside := mocks.CreateMockSpatial(3, 150, 95, -20)
+	node.PutNearest(outside)
+	// the nearest point is (100, 95, 0).
+	assert.Equal(t, []siface.ISpatial{outside}, entitiesOf(node.Children().GetChild(6)))
+	assert.Equal(t, []siface.ISpatial{outside}, node.FindEntities(geo.NewVec3Int(130, 95, -10), 30))
+	assert.Empty(t, node.FindEntities(geo.NewVec3Int(100, 95, 0), 30))
+	// the node holding it divides, it stays.
+	node.Children().GetChild(6).Add(mocks.CreateMockSpatial(4, 80, 80, 20))
+	assert.Equal(t, []siface.ISpatial{outside}, entitiesOf(node.Children().GetChild(6)))
+	assert.Equal(t, []siface.ISpatial{outside}, node.FindNearest(geo.NewVec3Int(150, 95, -20), 1, 0))
+}
@@ -0,0 +1,30 @@
+// Package treenode .
+package treenode
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"sync"
+)
+
+// query holds the buffers of the center and the bound of a search,
+// they are reused through queryPool so that a search doesn't allocate.
+type query struct {
+	center [3]int32
+	min    [3]int32
+	max    [3]int32
+	mid    [3]int32
+}
+
+var queryPool = sync.Pool{
+	New: func() any {
+		return &query{}
+	},
+}
+
+// bound returns the bound from min to max, backed by the buffers of the query.
+func (q *query) bound() bounds.Bound {
+	for i := range q.mid {
+		q.mid[i] = (q.min[i] + q.max[i]) / 2
+	}
+	return bounds.Bound{Min: q.min[:], Max: q.max[:], Center: q.mid[:]}
+}
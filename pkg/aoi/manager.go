@@ -0,0 +1,316 @@
+// Package aoi provides an area of interest manager, which emits enter/leave/move events
+// between watchers and the entities around them on top of any siface.ISearch.
+package aoi
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+)
+
+// Listener receives the events of the manager.
+type Listener interface {
+	// OnEnter is called when the target enters the view of the watcher.
+	OnEnter(watcher, target siface.ISpatial)
+	// OnLeave is called when the target leaves the view of the watcher.
+	OnLeave(watcher, target siface.ISpatial)
+	// OnMove is called when the target moves within the view of the watcher.
+	OnMove(watcher, target siface.ISpatial)
+}
+
+// watcher is an entity watching the entities around it.
+type watcher struct {
+	entity      siface.ISpatial
+	enterRadius float32         // targets closer than enterRadius enter the view.
+	leaveRadius float32         // targets farther than leaveRadius leave the view.
+	visible     map[int64]sight // visible targets.
+}
+
+// sight is a target in the view of a watcher.
+type sight struct {
+	target   siface.ISpatial
+	location geo.Vec3Int // the location of the target when last seen.
+}
+
+// Manager manages the views of the watchers.
+//
+// Not thread-safe, only works in a single thread(goroutine).
+type Manager struct {
+	search    siface.ISearch
+	listener  Listener
+	watchers  map[int64]*watcher
+	observers map[int64]map[int64]struct{} // target ID -> IDs of the watchers seeing it.
+	maxRadius float32                      // the maximum leave radius of the watchers.
+	radii     map[float32]int              // leave radius -> the number of the watchers with it, to recompute maxRadius.
+	center    func(location geo.Vec3Int) []float32
+	distance  func(p1, p2 []float32) float32
+}
+
+// Option is a function type used to configure the manager.
+type Option func(m *Manager)
+
+// WithCenter sets the function converting a location to the center passed to ISearch.GetSurroundingEntities,
+// it should be the inverse of the scale function of the index. Default is a plain conversion to float32.
+func WithCenter(f func(location geo.Vec3Int) []float32) Option {
+	return func(m *Manager) {
+		m.center = f
+	}
+}
+
+// WithDistance sets the distance used to compare with the enter and leave radius,
+// it should match the distance used by the index. Default is util.Distance3D.
+func WithDistance(f func(p1, p2 []float32) float32) Option {
+	return func(m *Manager) {
+		m.distance = f
+	}
+}
+
+// NewManager creates a new manager.
+// Parameters:
+// - search: the index holding the entities.
+// - listener: the listener receiving the events.
+// - opts: variadic optional parameters to configure the manager.
+func NewManager(search siface.ISearch, listener Listener, opts ...Option) *Manager {
+	m := &Manager{
+		search:    search,
+		listener:  listener,
+		watchers:  make(map[int64]*watcher),
+		observers: make(map[int64]map[int64]struct{}),
+		radii:     make(map[float32]int),
+		center:    geo.Vec3Int.ToFloat32,
+		distance:  util.Distance3D,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Watch registers an entity as a watcher, the entities around it enter its view on the next Tick or Move.
+// Parameters:
+// - entity: the watcher.
+// - enterRadius: targets closer than enterRadius enter the view.
+// - leaveRadius: optional, targets farther than leaveRadius leave the view, default is enterRadius.
+// A leaveRadius greater than enterRadius prevents targets from flickering at the boundary.
+func (m *Manager) Watch(entity siface.ISpatial, enterRadius float32, leaveRadius ...float32) {
+	w := &watcher{
+		entity:      entity,
+		enterRadius: enterRadius,
+		leaveRadius: enterRadius,
+		visible:     make(map[int64]sight),
+	}
+	if len(leaveRadius) > 0 && leaveRadius[0] > enterRadius {
+		w.leaveRadius = leaveRadius[0]
+	}
+	if old, ok := m.watchers[entity.GetID()]; ok {
+		w.visible = old.visible
+		m.untrack(old.leaveRadius)
+	}
+	m.watchers[entity.GetID()] = w
+	m.radii[w.leaveRadius]++
+	m.maxRadius = max(m.maxRadius, w.leaveRadius)
+}
+
+// Unwatch unregisters a watcher without emitting any event.
+// Returns true if the entity was a watcher, false otherwise.
+func (m *Manager) Unwatch(entityId int64) bool {
+	w, ok := m.watchers[entityId]
+	if !ok {
+		return false
+	}
+	for id := range w.visible {
+		m.unobserve(id, entityId)
+	}
+	delete(m.watchers, entityId)
+	m.untrack(w.leaveRadius)
+	return true
+}
+
+// untrack forgets a leave radius of a watcher gone, maxRadius shrinks if it was the last one of the maximum.
+func (m *Manager) untrack(radius float32) {
+	if m.radii[radius]--; m.radii[radius] > 0 {
+		return
+	}
+	delete(m.radii, radius)
+	if radius == m.maxRadius {
+		m.maxRadius = 0
+		for r := range m.radii {
+			m.maxRadius = max(m.maxRadius, r)
+		}
+	}
+}
+
+// Add adds an entity to the index, and lets it enter the views of the watchers around.
+// Returns false if the index rejected the entity.
+func (m *Manager) Add(entity siface.ISpatial) bool {
+	if !m.search.Add(entity) {
+		return false
+	}
+	m.refreshObservers(entity)
+	if w, ok := m.watchers[entity.GetID()]; ok {
+		m.refresh(w)
+	}
+	return true
+}
+
+// Remove removes an entity from the index, and lets it leave the views of its watchers.
+// If the entity is a watcher, it's unwatched as well.
+// Returns false if the entity isn't in the index.
+func (m *Manager) Remove(entityId int64) bool {
+	entity, ok := m.search.Get(entityId)
+	if !ok || !m.search.Remove(entityId) {
+		return false
+	}
+	for id := range m.observers[entityId] {
+		w := m.watchers[id]
+		delete(w.visible, entityId)
+		m.listener.OnLeave(w.entity, entity)
+	}
+	delete(m.observers, entityId)
+	m.Unwatch(entityId)
+	return true
+}
+
+// Move updates an entity which has moved from oldLocation in the index,
+// then refreshes its view if it's a watcher, and its relation with the watchers around.
+// Returns false if the index failed to update the entity.
+func (m *Manager) Move(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	if !m.search.Update(entity, oldLocation) {
+		return false
+	}
+	if w, ok := m.watchers[entity.GetID()]; ok {
+		w.entity = entity
+		m.refresh(w)
+	}
+	m.refreshObservers(entity)
+	return true
+}
+
+// Tick refreshes the views of all the watchers,
+// it catches up with the changes made to the index without going through the manager.
+func (m *Manager) Tick() {
+	for _, w := range m.watchers {
+		if entity, ok := m.search.Get(w.entity.GetID()); ok {
+			w.entity = entity
+		}
+		m.refresh(w)
+	}
+}
+
+// Visible returns the IDs of the targets in the view of the watcher.
+func (m *Manager) Visible(watcherId int64) []int64 {
+	w, ok := m.watchers[watcherId]
+	if !ok {
+		return nil
+	}
+	ret := make([]int64, 0, len(w.visible))
+	for id := range w.visible {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+// refresh recomputes the view of the watcher.
+func (m *Manager) refresh(w *watcher) {
+	id := w.entity.GetID()
+	location := w.entity.GetLocation()
+	seen := make(map[int64]struct{}, len(w.visible))
+	for _, target := range m.search.GetSurroundingEntities(m.center(location), w.leaveRadius) {
+		if target.GetID() == id {
+			continue
+		}
+		if m.relate(w, target) {
+			seen[target.GetID()] = struct{}{}
+		}
+	}
+	for targetId := range w.visible {
+		if _, ok := seen[targetId]; ok {
+			continue
+		}
+		target, ok := m.search.Get(targetId)
+		if !ok {
+			// removed from the index directly.
+			target = w.visible[targetId].target
+		}
+		if !ok || !m.relate(w, target) {
+			delete(w.visible, targetId)
+			m.unobserve(targetId, id)
+			m.listener.OnLeave(w.entity, target)
+		}
+	}
+}
+
+// refreshObservers recomputes the relation between the target and the watchers around it or seeing it.
+func (m *Manager) refreshObservers(target siface.ISpatial) {
+	if len(m.watchers) == 0 {
+		return
+	}
+	id := target.GetID()
+	checked := make(map[int64]struct{})
+	for _, entity := range m.search.GetSurroundingEntities(m.center(target.GetLocation()), m.maxRadius) {
+		if w, ok := m.watchers[entity.GetID()]; ok && entity.GetID() != id {
+			checked[entity.GetID()] = struct{}{}
+			m.relate(w, target)
+		}
+	}
+	for watcherId := range m.observers[id] {
+		if _, ok := checked[watcherId]; ok {
+			continue
+		}
+		w := m.watchers[watcherId]
+		if !m.relate(w, target) {
+			delete(w.visible, id)
+			m.unobserve(id, watcherId)
+			m.listener.OnLeave(w.entity, target)
+		}
+	}
+}
+
+// relate updates the relation between the watcher and the target, emitting the enter and move events.
+// Returns true if the target stays or enters in the view of the watcher.
+// The leave event is left to the caller, so that the targets can be left while ranging the view.
+func (m *Manager) relate(w *watcher, target siface.ISpatial) bool {
+	id := target.GetID()
+	location := target.GetLocation()
+	dist := m.distance(w.entity.GetLocation().ToFloat32(), location.ToFloat32())
+	if last, ok := w.visible[id]; ok {
+		if dist > w.leaveRadius {
+			return false
+		}
+		w.visible[id] = sight{target: target, location: clone(location)}
+		if !equal(last.location, location) {
+			m.listener.OnMove(w.entity, target)
+		}
+		return true
+	}
+	if dist > w.enterRadius {
+		return false
+	}
+	w.visible[id] = sight{target: target, location: clone(location)}
+	m.observe(id, w.entity.GetID())
+	m.listener.OnEnter(w.entity, target)
+	return true
+}
+
+func (m *Manager) observe(targetId, watcherId int64) {
+	if _, ok := m.observers[targetId]; !ok {
+		m.observers[targetId] = make(map[int64]struct{})
+	}
+	m.observers[targetId][watcherId] = struct{}{}
+}
+
+func (m *Manager) unobserve(targetId, watcherId int64) {
+	delete(m.observers[targetId], watcherId)
+	if len(m.observers[targetId]) == 0 {
+		delete(m.observers, targetId)
+	}
+}
+
+// clone copies the location, entities may update their location in place.
+func clone(v geo.Vec3Int) geo.Vec3Int {
+	return geo.NewVec3Int(v.X(), v.Y(), v.Z())
+}
+
+func equal(v1, v2 geo.Vec3Int) bool {
+	return v1.X() == v2.X() && v1.Y() == v2.Y() && v1.Z() == v2.Z()
+}
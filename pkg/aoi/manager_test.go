@@ -0,0 +1,151 @@
+package aoi
+
+import (
+	"fmt"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/pkg/zearches"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) OnEnter(watcher, target siface.ISpatial) {
+	r.events = append(r.events, fmt.Sprintf("enter %d %d", watcher.GetID(), target.GetID()))
+}
+
+func (r *recorder) OnLeave(watcher, target siface.ISpatial) {
+	r.events = append(r.events, fmt.Sprintf("leave %d %d", watcher.GetID(), target.GetID()))
+}
+
+func (r *recorder) OnMove(watcher, target siface.ISpatial) {
+	r.events = append(r.events, fmt.Sprintf("move %d %d", watcher.GetID(), target.GetID()))
+}
+
+func (r *recorder) take() []string {
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func newManager(t *testing.T) (*Manager, *recorder) {
+	search, err := zearches.CreateOctree(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100)), 3, 2)
+	assert.Nil(t, err)
+	r := &recorder{}
+	return NewManager(search, r), r
+}
+
+func moveTo(m *Manager, entity siface.ISpatial, x, y, z int32) bool {
+	mock := entity.(*mocks.MockSpatial)
+	old := mock.Location
+	mock.Location = geo.NewVec3Int(x, y, z)
+	return m.Move(entity, old)
+}
+
+func TestManager_EnterLeaveMove(t *testing.T) {
+	m, r := newManager(t)
+	watcher := mocks.CreateMockSpatial(1, 10, 10, 10)
+	target := mocks.CreateMockSpatial(2, 15, 10, 10)
+	m.Watch(watcher, 10)
+	assert.True(t, m.Add(watcher))
+	assert.True(t, m.Add(target))
+	assert.Equal(t, []string{"enter 1 2"}, r.take())
+	assert.Equal(t, []int64{2}, m.Visible(1))
+
+	assert.True(t, moveTo(m, target, 18, 10, 10))
+	assert.Equal(t, []string{"move 1 2"}, r.take())
+
+	assert.True(t, moveTo(m, target, 30, 10, 10))
+	assert.Equal(t, []string{"leave 1 2"}, r.take())
+	assert.Empty(t, m.Visible(1))
+
+	// the watcher moves toward the target
+	assert.True(t, moveTo(m, watcher, 25, 10, 10))
+	assert.Equal(t, []string{"enter 1 2"}, r.take())
+
+	assert.True(t, m.Remove(2))
+	assert.Equal(t, []string{"leave 1 2"}, r.take())
+	assert.False(t, m.Remove(2))
+}
+
+func TestManager_Hysteresis(t *testing.T) {
+	m, r := newManager(t)
+	watcher := mocks.CreateMockSpatial(1, 10, 10, 10)
+	target := mocks.CreateMockSpatial(2, 25, 10, 10)
+	m.Watch(watcher, 10, 20)
+	m.Add(watcher)
+	m.Add(target)
+	assert.Empty(t, r.take())
+
+	moveTo(m, target, 19, 10, 10)
+	assert.Equal(t, []string{"enter 1 2"}, r.take())
+	// beyond the enter radius, but within the leave radius
+	moveTo(m, target, 25, 10, 10)
+	assert.Equal(t, []string{"move 1 2"}, r.take())
+	moveTo(m, target, 31, 10, 10)
+	assert.Equal(t, []string{"leave 1 2"}, r.take())
+}
+
+func TestManager_Tick(t *testing.T) {
+	search, _ := zearches.CreateOctree(bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100)), 3, 2)
+	r := &recorder{}
+	m := NewManager(search, r)
+	watcher := mocks.CreateMockSpatial(1, 10, 10, 10)
+	target := mocks.CreateMockSpatial(2, 15, 10, 10)
+	search.Add(watcher)
+	search.Add(target)
+	m.Watch(watcher, 10)
+	assert.Empty(t, r.take())
+
+	m.Tick()
+	assert.Equal(t, []string{"enter 1 2"}, r.take())
+	m.Tick()
+	assert.Empty(t, r.take())
+
+	// changes made to the index directly are caught up by Tick
+	search.Remove(2)
+	m.Tick()
+	assert.Equal(t, []string{"leave 1 2"}, r.take())
+
+	assert.True(t, m.Unwatch(1))
+	assert.False(t, m.Unwatch(1))
+	m.Tick()
+	assert.Empty(t, r.take())
+}
+
+func TestManager_MutualWatchers(t *testing.T) {
+	m, r := newManager(t)
+	a := mocks.CreateMockSpatial(1, 10, 10, 10)
+	b := mocks.CreateMockSpatial(2, 50, 10, 10)
+	m.Watch(a, 10)
+	m.Watch(b, 10)
+	m.Add(a)
+	m.Add(b)
+	assert.Empty(t, r.take())
+	moveTo(m, b, 15, 10, 10)
+	assert.ElementsMatch(t, []string{"enter 2 1", "enter 1 2"}, r.take())
+	m.Remove(1)
+	assert.ElementsMatch(t, []string{"leave 2 1"}, r.take())
+}
+
+func TestManager_MaxRadius(t *testing.T) {
+	m, _ := newManager(t)
+	m.Watch(mocks.CreateMockSpatial(1, 10, 10, 10), 5)
+	m.Watch(mocks.CreateMockSpatial(2, 20, 10, 10), 50, 80)
+	m.Watch(mocks.CreateMockSpatial(3, 30, 10, 10), 80)
+	assert.Equal(t, float32(80), m.maxRadius)
+	m.Unwatch(2)
+	assert.Equal(t, float32(80), m.maxRadius)
+	// watched again with a smaller radius.
+	m.Watch(mocks.CreateMockSpatial(3, 30, 10, 10), 20)
+	assert.Equal(t, float32(20), m.maxRadius)
+	m.Unwatch(3)
+	assert.Equal(t, float32(5), m.maxRadius)
+	m.Unwatch(1)
+	assert.Equal(t, float32(0), m.maxRadius)
+}
@@ -27,3 +27,64 @@ func NewBound(min, max geo.Vec3Int) Bound {
 		Center: geo.NewVec3Int((min.X()+max.X())/2, (min.Y()+max.Y())/2, (min.Z()+max.Z())/2),
 	}
 }
+
+// Contains checks if the point is within the bound, boundaries included.
+func (b Bound) Contains(p geo.Vec3Int) bool {
+	return b.Min.X() <= p.X() && p.X() <= b.Max.X() &&
+		b.Min.Y() <= p.Y() && p.Y() <= b.Max.Y() &&
+		b.Min.Z() <= p.Z() && p.Z() <= b.Max.Z()
+}
+
+// Contains2D checks if the point is within the bound on the x and z axes, ignoring y.
+func (b Bound) Contains2D(p geo.Vec3Int) bool {
+	return b.Min.X() <= p.X() && p.X() <= b.Max.X() &&
+		b.Min.Z() <= p.Z() && p.Z() <= b.Max.Z()
+}
+
+// ContainsAxes checks if the point is within the bound on the given axes(0 for x, 1 for y, 2 for z), ignoring the others.
+func (b Bound) ContainsAxes(p geo.Vec3Int, axes ...int) bool {
+	for _, axis := range axes {
+		if p[axis] < b.Min[axis] || b.Max[axis] < p[axis] {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersects checks if the bound overlaps the other bound, touching boundaries included.
+func (b Bound) Intersects(other Bound) bool {
+	return b.Min.X() <= other.Max.X() && other.Min.X() <= b.Max.X() &&
+		b.Min.Y() <= other.Max.Y() && other.Min.Y() <= b.Max.Y() &&
+		b.Min.Z() <= other.Max.Z() && other.Min.Z() <= b.Max.Z()
+}
+
+// IntersectsAxes checks if the bound overlaps the other bound on the given axes, ignoring the others.
+func (b Bound) IntersectsAxes(other Bound, axes ...int) bool {
+	for _, axis := range axes {
+		if other.Max[axis] < b.Min[axis] || b.Max[axis] < other.Min[axis] {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersects2D checks if the bound overlaps the other bound on the x and z axes, ignoring y.
+func (b Bound) Intersects2D(other Bound) bool {
+	return b.Min.X() <= other.Max.X() && other.Min.X() <= b.Max.X() &&
+		b.Min.Z() <= other.Max.Z() && other.Min.Z() <= b.Max.Z()
+}
+
+// Encloses checks if the other bound is within the bound, boundaries included.
+func (b Bound) Encloses(other Bound) bool {
+	return b.EnclosesAxes(other, 0, 1, 2)
+}
+
+// EnclosesAxes checks if the other bound is within the bound on the given axes, ignoring the others.
+func (b Bound) EnclosesAxes(other Bound, axes ...int) bool {
+	for _, axis := range axes {
+		if other.Min[axis] < b.Min[axis] || b.Max[axis] < other.Max[axis] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+// Package collision provides simple intersection detection between spheres, rays, segments and axis-aligned boxes.
+package collision
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"math"
+)
+
+// AABB checks if two axis-aligned boxes overlap, touching boundaries included.
+func AABB(a, b bounds.Bound) bool {
+	return a.Intersects(b)
+}
+
+// SphereSphere checks if two spheres overlap, touching included.
+func SphereSphere(c1 geo.Vec3Int, r1 float32, c2 geo.Vec3Int, r2 float32) bool {
+	dx := float64(c1.X()) - float64(c2.X())
+	dy := float64(c1.Y()) - float64(c2.Y())
+	dz := float64(c1.Z()) - float64(c2.Z())
+	r := float64(r1) + float64(r2)
+	return dx*dx+dy*dy+dz*dz <= r*r
+}
+
+// SphereAABB checks if a sphere overlaps an axis-aligned box, touching included.
+func SphereAABB(center geo.Vec3Int, radius float32, b bounds.Bound) bool {
+	d := DistanceSquared(center, b)
+	return d <= float64(radius)*float64(radius)
+}
+
+// DistanceSquared returns the squared distance between a point and an axis-aligned box,
+// it's 0 if the point is within the box.
+func DistanceSquared(p geo.Vec3Int, b bounds.Bound) float64 {
+	sum := 0.0
+	for i := 0; i < 3; i++ {
+		v := float64(p[i])
+		if low := float64(b.Min[i]); v < low {
+			sum += (low - v) * (low - v)
+		} else if high := float64(b.Max[i]); v > high {
+			sum += (v - high) * (v - high)
+		}
+	}
+	return sum
+}
+
+// RayAABB checks if a ray hits an axis-aligned box.
+//
+// Parameters:
+// - origin: the origin of the ray.
+// - direction: the direction of the ray, it needn't be normalized.
+// - b: the box to test.
+//
+// Returns:
+// - the parameter t of the entry point, which is origin + t * direction, 0 if the origin is within the box.
+// - true if the ray hits the box, false otherwise.
+func RayAABB(origin, direction geo.Vec3Int, b bounds.Bound) (float32, bool) {
+	tMin, tMax, ok := slabs(origin, direction, b)
+	if !ok || tMax < 0 {
+		return 0, false
+	}
+	return float32(math.Max(tMin, 0)), true
+}
+
+// SegmentAABB checks if the segment from p0 to p1 intersects an axis-aligned box.
+func SegmentAABB(p0, p1 geo.Vec3Int, b bounds.Bound) bool {
+	direction := geo.NewVec3Int(p1.X()-p0.X(), p1.Y()-p0.Y(), p1.Z()-p0.Z())
+	tMin, tMax, ok := slabs(p0, direction, b)
+	return ok && tMax >= 0 && tMin <= 1
+}
+
+// slabs clips the line origin + t * direction against the slabs of the box,
+// it returns the parameter range [tMin, tMax] within the box.
+func slabs(origin, direction geo.Vec3Int, b bounds.Bound) (float64, float64, bool) {
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+	for i := 0; i < 3; i++ {
+		o, d := float64(origin[i]), float64(direction[i])
+		low, high := float64(b.Min[i]), float64(b.Max[i])
+		if d == 0 {
+			// parallel to the slab, the origin must lie within it.
+			if o < low || o > high {
+				return 0, 0, false
+			}
+			continue
+		}
+		t1, t2 := (low-o)/d, (high-o)/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = math.Max(tMin, t1)
+		tMax = math.Min(tMax, t2)
+		if tMin > tMax {
+			return 0, 0, false
+		}
+	}
+	return tMin, tMax, true
+}
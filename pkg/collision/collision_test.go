@@ -0,0 +1,58 @@
+package collision
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func box(x0, y0, z0, x1, y1, z1 int32) bounds.Bound {
+	return bounds.NewBound(geo.NewVec3Int(x0, y0, z0), geo.NewVec3Int(x1, y1, z1))
+}
+
+func TestAABB(t *testing.T) {
+	assert.True(t, AABB(box(0, 0, 0, 10, 10, 10), box(5, 5, 5, 15, 15, 15)))
+	assert.True(t, AABB(box(0, 0, 0, 10, 10, 10), box(10, 10, 10, 15, 15, 15))) // touching
+	assert.False(t, AABB(box(0, 0, 0, 10, 10, 10), box(11, 0, 0, 15, 10, 10)))
+	assert.False(t, AABB(box(0, 0, 0, 10, 10, 10), box(0, 11, 0, 10, 15, 10)))
+}
+
+func TestSphereSphere(t *testing.T) {
+	assert.True(t, SphereSphere(geo.NewVec3Int(0, 0, 0), 5, geo.NewVec3Int(8, 0, 0), 3))
+	assert.False(t, SphereSphere(geo.NewVec3Int(0, 0, 0), 5, geo.NewVec3Int(8, 1, 0), 3))
+}
+
+func TestSphereAABB(t *testing.T) {
+	b := box(0, 0, 0, 10, 10, 10)
+	assert.True(t, SphereAABB(geo.NewVec3Int(5, 5, 5), 1, b)) // inside
+	assert.True(t, SphereAABB(geo.NewVec3Int(13, 5, 5), 3, b))
+	assert.False(t, SphereAABB(geo.NewVec3Int(13, 13, 5), 3, b)) // near the edge, but beyond the radius
+	assert.True(t, SphereAABB(geo.NewVec3Int(13, 14, 5), 5, b))
+}
+
+func TestRayAABB(t *testing.T) {
+	b := box(10, 0, 0, 20, 10, 10)
+	d, hit := RayAABB(geo.NewVec3Int(0, 5, 5), geo.NewVec3Int(1, 0, 0), b)
+	assert.True(t, hit)
+	assert.Equal(t, float32(10), d)
+	_, hit = RayAABB(geo.NewVec3Int(0, 5, 5), geo.NewVec3Int(-1, 0, 0), b) // pointing away
+	assert.False(t, hit)
+	_, hit = RayAABB(geo.NewVec3Int(0, 20, 5), geo.NewVec3Int(1, 0, 0), b) // parallel, outside the slab
+	assert.False(t, hit)
+	d, hit = RayAABB(geo.NewVec3Int(15, 5, 5), geo.NewVec3Int(0, 1, 0), b) // origin inside
+	assert.True(t, hit)
+	assert.Equal(t, float32(0), d)
+	d, hit = RayAABB(geo.NewVec3Int(0, -10, 5), geo.NewVec3Int(1, 1, 0), b)
+	assert.True(t, hit)
+	assert.Equal(t, float32(10), d)
+}
+
+func TestSegmentAABB(t *testing.T) {
+	b := box(10, 0, 0, 20, 10, 10)
+	assert.True(t, SegmentAABB(geo.NewVec3Int(0, 5, 5), geo.NewVec3Int(10, 5, 5), b))
+	assert.False(t, SegmentAABB(geo.NewVec3Int(0, 5, 5), geo.NewVec3Int(9, 5, 5), b))
+	assert.True(t, SegmentAABB(geo.NewVec3Int(15, 5, 5), geo.NewVec3Int(16, 5, 5), b)) // inside
+	assert.True(t, SegmentAABB(geo.NewVec3Int(30, 5, 5), geo.NewVec3Int(0, 5, 5), b))
+	assert.False(t, SegmentAABB(geo.NewVec3Int(0, 11, 5), geo.NewVec3Int(30, 11, 5), b))
+}
@@ -0,0 +1,68 @@
+// Package filter provides the filters of the search queries and the combinators of them.
+//
+// All the searches apply the filters passed to a query as a conjunction:
+// an entity is returned only if every filter accepts it, no filter accepts all the entities.
+// Use Any to accept the entities passing any of several filters.
+package filter
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/siface"
+)
+
+// Filter accepts an entity if it returns true.
+type Filter = func(entity siface.ISpatial) bool
+
+// Match checks if the entity passes all the filters, it always passes if there is no filter.
+func Match(entity siface.ISpatial, filters ...Filter) bool {
+	for _, f := range filters {
+		if !f(entity) {
+			return false
+		}
+	}
+	return true
+}
+
+// All accepts the entities passing all the filters, it accepts all the entities if there is no filter.
+func All(filters ...Filter) Filter {
+	return func(entity siface.ISpatial) bool {
+		return Match(entity, filters...)
+	}
+}
+
+// Any accepts the entities passing any of the filters, it accepts none if there is no filter.
+func Any(filters ...Filter) Filter {
+	return func(entity siface.ISpatial) bool {
+		for _, f := range filters {
+			if f(entity) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not accepts the entities refused by the filter.
+func Not(f Filter) Filter {
+	return func(entity siface.ISpatial) bool {
+		return !f(entity)
+	}
+}
+
+// ByIDs accepts the entities with any of the given IDs.
+func ByIDs(ids ...int64) Filter {
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return func(entity siface.ISpatial) bool {
+		_, ok := set[entity.GetID()]
+		return ok
+	}
+}
+
+// ExcludeID refuses the entity with the given ID, typically the one searching around itself.
+func ExcludeID(id int64) Filter {
+	return func(entity siface.ISpatial) bool {
+		return entity.GetID() != id
+	}
+}
@@ -0,0 +1,31 @@
+// Package filter .
+package filter
+
+import (
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCombinators(t *testing.T) {
+	entity := mocks.CreateMockSpatial(1, 0, 0, 0)
+	yes := func(_ siface.ISpatial) bool { return true }
+	no := Not(yes)
+
+	assert.True(t, Match(entity))
+	assert.True(t, Match(entity, yes, yes))
+	assert.False(t, Match(entity, yes, no))
+
+	assert.True(t, All()(entity))
+	assert.False(t, All(yes, no)(entity))
+	assert.False(t, Any()(entity))
+	assert.True(t, Any(no, yes)(entity))
+	assert.False(t, Any(no, no)(entity))
+
+	assert.True(t, ByIDs(3, 1)(entity))
+	assert.False(t, ByIDs(2)(entity))
+	assert.False(t, ByIDs()(entity))
+	assert.False(t, ExcludeID(1)(entity))
+	assert.True(t, ExcludeID(2)(entity))
+}
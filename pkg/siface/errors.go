@@ -0,0 +1,12 @@
+// Package siface .
+package siface
+
+import "errors"
+
+// The errors of the mutations of ISearch, like AddE, they are wrapped with the ID of the entity, test them with errors.Is.
+var (
+	ErrOutOfBounds  = errors.New("entity out of bounds") // the location is out of the bound of the search tree.
+	ErrDuplicateID  = errors.New("duplicate entity id")  // an entity with the same ID is in the search tree.
+	ErrNotFound     = errors.New("entity not found")     // no entity with the ID is in the search tree.
+	ErrInvalidBound = errors.New("invalid entity bound") // the bound is set partially, or its min exceeds its max.
+)
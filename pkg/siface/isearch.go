@@ -1,14 +1,106 @@
 // Package siface .
 package siface
 
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+)
+
 // ISearch  interface for search, like Octree, QuadTree, RTree, etc.
+//
+// The filters of the queries are combined as a conjunction: an entity is returned only if
+// every filter returns true for it, see package filter for the combinators like Any and Not.
 type ISearch interface {
-	// Add adds an entity to the search tree.
+	// Add adds an entity to the search tree, it's AddE without the reason of the failure.
 	Add(entity ISpatial) bool
-	// Remove removes an entity from the search tree by its ID.
+	// AddE adds an entity to the search tree, returns ErrOutOfBounds, ErrDuplicateID or ErrInvalidBound if it's rejected.
+	// An entity whose ID is in the search tree is rejected, replaced or moved by the duplicate policy of the tree.
+	AddE(entity ISpatial) error
+	// AddBatch adds the entities in one pass, faster than adding them one by one to build a tree,
+	// returns the number of entities added, the ones rejected by AddE are skipped.
+	AddBatch(entities []ISpatial) int
+	// Remove removes an entity from the search tree by its ID, it's RemoveE without the reason of the failure.
 	Remove(entityId int64) bool
+	// RemoveE removes an entity from the search tree by its ID, returns ErrNotFound if it's not in the search tree.
+	RemoveE(entityId int64) error
+	// Get returns the entity with the given ID.
+	Get(entityId int64) (ISpatial, bool)
+	// Contains checks if an entity with the given ID is in the search tree.
+	Contains(entityId int64) bool
+	// Update relocates an entity that has moved from oldLocation to its current location,
+	// it's UpdateE without the reason of the failure.
+	Update(entity ISpatial, oldLocation geo.Vec3Int) bool
+	// UpdateE relocates an entity like Update, returns ErrNotFound, ErrOutOfBounds or ErrInvalidBound if it fails,
+	// the entity stays where it was then.
+	UpdateE(entity ISpatial, oldLocation geo.Vec3Int) error
 	// GetSurroundingEntities finds entities within a certain radius of a center point.
 	GetSurroundingEntities(center []float32, radius float32, filters ...func(entity ISpatial) bool) []ISpatial
+	// AppendSurroundingEntities appends the entities found by GetSurroundingEntities to dst and returns the extended slice,
+	// it doesn't allocate if dst has enough capacity.
+	AppendSurroundingEntities(dst []ISpatial, center []float32, radius float32, filters ...func(entity ISpatial) bool) []ISpatial
+	// VisitSurroundingEntities calls visit for each entity found by GetSurroundingEntities, until visit returns false.
+	VisitSurroundingEntities(center []float32, radius float32, visit func(entity ISpatial) bool, filters ...func(entity ISpatial) bool)
+	// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric,
+	// the metric of the search tree is used if it's nil.
+	GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity ISpatial) bool) []ISpatial
+	// GetNearest finds at most k entities nearest to a center point, sorted by ascending distance.
+	// maxDistance limits the distance of the entities, there is no limit if it's not positive.
+	GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity ISpatial) bool) []ISpatial
+	// GetEntitiesInBound finds entities within an axis-aligned box, boundaries included.
+	GetEntitiesInBound(bound bounds.Bound, filters ...func(entity ISpatial) bool) []ISpatial
+	// GetIntersecting finds entities whose bound intersects the bound, touching boundaries included.
+	GetIntersecting(bound bounds.Bound, filters ...func(entity ISpatial) bool) []ISpatial
+	// GetCollisionPairs finds all pairs of entities whose bounds overlap, each pair is reported once.
+	GetCollisionPairs() [][2]ISpatial
+	// All returns an iterator over all the entities, in no particular order.
+	All() iter.Seq[ISpatial]
+	// Within returns an iterator over the entities found by GetSurroundingEntities, the search stops when the loop breaks.
+	Within(center []float32, radius float32, filters ...func(entity ISpatial) bool) iter.Seq[ISpatial]
+	// Len returns the number of entities in the search tree.
+	Len() int
+	// Stats returns the statistics of the search tree, it walks the whole tree.
+	Stats() Stats
+	// Snapshot writes the config, the structure and the entity IDs/locations of the search tree to w
+	// in a versioned binary format, see zearches.Restore.
+	Snapshot(w io.Writer) error
 	// ToDot generates a dot file for the search tree.
 	ToDot() error
 }
+
+// IMoveDiff is implemented by the searches which detect the entities entering and leaving
+// the surroundings of a moving entity incrementally, like the cross-linked list.
+type IMoveDiff interface {
+	// UpdateWithDiff relocates an entity like Update, and reports the entities entering and leaving
+	// the surroundings within radius of it.
+	UpdateWithDiff(entity ISpatial, oldLocation geo.Vec3Int, radius float32) (enter, leave []ISpatial, ok bool)
+}
+
+// NodeInfo describes a node of a search tree, for debugging tools.
+type NodeInfo struct {
+	Bound bounds.Bound // The bound of the node.
+	Depth int          // The depth of the node, 0 for the root.
+	Count int          // The number of entities held by the node itself, not including its descendants.
+}
+
+// INodes is implemented by the searches made of nodes, like the octree and quadtree.
+type INodes interface {
+	// Nodes returns an iterator over the nodes in depth-first order.
+	Nodes() iter.Seq[NodeInfo]
+}
+
+// Stats describes the shape and the size of a search tree, for tuning and monitoring.
+// The searches without nodes or leaves leave the fields about them zero, like the cross-linked list.
+type Stats struct {
+	Entities        int     // The number of entities.
+	Nodes           int     // The number of nodes, the cells for a grid.
+	Leaves          int     // The number of leaves, the cells holding entities for a grid.
+	Depth           int     // The depth of the deepest leaf, 0 if the root is the only node.
+	DepthHistogram  []int   // The number of leaves at each depth.
+	MaxLeafEntities int     // The maximum number of entities held by a leaf.
+	AvgLeafEntities float64 // The average number of entities held by a leaf, not counting the entities held by the internal nodes.
+	Overflow        int     // The number of entities in the leaves at the maximum depth holding more than the capacity.
+	MemoryBytes     int     // The estimated memory used by the search tree, not including the entities themselves.
+}
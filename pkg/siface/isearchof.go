@@ -0,0 +1,66 @@
+// Package siface .
+package siface
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+)
+
+// ISearchOf is the typed ISearch of the entities of type T, like *Player,
+// the filters take T and the queries return T, no type assertion is needed by the callers.
+type ISearchOf[T ISpatial] interface {
+	// Add adds an entity to the search tree.
+	Add(entity T) bool
+	// AddE adds an entity to the search tree, returns ErrOutOfBounds, ErrDuplicateID or ErrInvalidBound if it's rejected.
+	// An entity whose ID is in the search tree is rejected, replaced or moved by the duplicate policy of the tree.
+	AddE(entity T) error
+	// AddBatch adds the entities in one pass, faster than adding them one by one to build a tree,
+	// returns the number of entities added.
+	AddBatch(entities []T) int
+	// Remove removes an entity from the search tree by its ID.
+	Remove(entityId int64) bool
+	// RemoveE removes an entity from the search tree by its ID, returns ErrNotFound if it's not in the search tree.
+	RemoveE(entityId int64) error
+	// Get returns the entity with the given ID.
+	Get(entityId int64) (T, bool)
+	// Contains checks if an entity with the given ID is in the search tree.
+	Contains(entityId int64) bool
+	// Update relocates an entity that has moved from oldLocation to its current location.
+	Update(entity T, oldLocation geo.Vec3Int) bool
+	// UpdateE relocates an entity like Update, returns ErrNotFound, ErrOutOfBounds or ErrInvalidBound if it fails.
+	UpdateE(entity T, oldLocation geo.Vec3Int) error
+	// GetSurroundingEntities finds entities within a certain radius of a center point.
+	GetSurroundingEntities(center []float32, radius float32, filters ...func(entity T) bool) []T
+	// AppendSurroundingEntities appends the entities found by GetSurroundingEntities to dst and returns the extended slice.
+	AppendSurroundingEntities(dst []T, center []float32, radius float32, filters ...func(entity T) bool) []T
+	// VisitSurroundingEntities calls visit for each entity found by GetSurroundingEntities, until visit returns false.
+	VisitSurroundingEntities(center []float32, radius float32, visit func(entity T) bool, filters ...func(entity T) bool)
+	// GetSurroundingEntitiesWithMetric finds entities within a certain radius of a center point measured by the metric.
+	GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity T) bool) []T
+	// GetNearest finds at most k entities nearest to a center point, sorted by ascending distance.
+	GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity T) bool) []T
+	// GetEntitiesInBound finds entities within an axis-aligned box, boundaries included.
+	GetEntitiesInBound(bound bounds.Bound, filters ...func(entity T) bool) []T
+	// GetIntersecting finds entities whose bound intersects the bound, touching boundaries included.
+	GetIntersecting(bound bounds.Bound, filters ...func(entity T) bool) []T
+	// GetCollisionPairs finds all pairs of entities whose bounds overlap, each pair is reported once.
+	GetCollisionPairs() [][2]T
+	// All returns an iterator over all the entities, in no particular order.
+	All() iter.Seq[T]
+	// Within returns an iterator over the entities found by GetSurroundingEntities, the search stops when the loop breaks.
+	Within(center []float32, radius float32, filters ...func(entity T) bool) iter.Seq[T]
+	// Len returns the number of entities in the search tree.
+	Len() int
+	// Stats returns the statistics of the search tree, it walks the whole tree.
+	Stats() Stats
+	// Snapshot writes the config, the structure and the entity IDs/locations of the search tree to w
+	// in a versioned binary format, see zearches.Restore.
+	Snapshot(w io.Writer) error
+	// ToDot generates a dot file for the search tree.
+	ToDot() error
+	// Untyped returns the ISearch holding the entities.
+	Untyped() ISearch
+}
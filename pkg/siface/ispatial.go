@@ -12,7 +12,11 @@ type ISpatial interface {
 	GetLocation() geo.Vec3Int // returns the location of the spatial entity.
 	// GetBound returns the boundary of the spatial entity.
 	//
-	//it is meaningless in octree and quadtree, because octree and quadtree treat all entities as a point,
-	// but it is meaningful in rtree
+	// octree and quadtree index entities as a point at their location by default, the bound is only used by
+	// intersection queries, such as ISearch.GetIntersecting. With a loose factor or sticky (see zearches.WithLooseFactor
+	// and zearches.WithSticky), they index entities by their bound: an entity is held by the deepest node holding its
+	// location whose loose bound contains its bound, the root if it straddles the edge of the tree, and the queries
+	// match its bound instead of its location. rtree indexes entities by their bound.
+	// A zero Bound is treated as a point at the location.
 	GetBound() bounds.Bound
 }
@@ -0,0 +1,141 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// scatter returns n entities scattered over the bound of size 1000, like populate.
+func scatter(n int) []siface.ISpatial {
+	ret := make([]siface.ISpatial, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, mocks.CreateMockSpatial(int64(i+1), int32(i*7%1000), int32(i*13%1000), int32(i*31%1000)))
+	}
+	return ret
+}
+
+func nodesOf(search siface.ISearch) []siface.NodeInfo {
+	var ret []siface.NodeInfo
+	for info := range search.(siface.INodes).Nodes() {
+		ret = append(ret, info)
+	}
+	return ret
+}
+
+func TestAddBatch_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	entities := scatter(2000)
+	incremental, batch, mixed := createAll(t, bound), createAll(t, bound), createAll(t, bound)
+	populate(incremental, 2000)
+	center := []float32{500, 500, 500}
+	for name := range incremental {
+		assert.Equal(t, 2000, batch[name].AddBatch(entities), name)
+		for _, entity := range entities[:500] {
+			mixed[name].Add(entity)
+		}
+		assert.Equal(t, 1500, mixed[name].AddBatch(entities[500:]), name)
+		for _, search := range []siface.ISearch{batch[name], mixed[name]} {
+			assert.Equal(t, 2000, search.Len(), name)
+			assert.Equal(t, sortedIds(incremental[name].GetSurroundingEntities(center, 200)), sortedIds(search.GetSurroundingEntities(center, 200)), name)
+			assert.Equal(t, sortedIds(incremental[name].GetNearest(center, 10, 0)), sortedIds(search.GetNearest(center, 10, 0)), name)
+		}
+	}
+	// a node is divided if it holds more entities than its capacity whatever the order of insertion.
+	for _, name := range []string{"octree", "quadtree"} {
+		assert.Equal(t, nodesOf(incremental[name]), nodesOf(batch[name]), name)
+		assert.Equal(t, nodesOf(incremental[name]), nodesOf(mixed[name]), name)
+	}
+}
+
+func TestAddBatch_Skipped(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	entities := []siface.ISpatial{
+		mocks.CreateMockSpatial(1, 10, 10, 10),
+		mocks.CreateMockSpatial(2, 200, 10, 10),
+		mocks.CreateMockSpatial(3, 20, 20, 20),
+	}
+	for _, name := range []string{"octree", "quadtree", "grid"} {
+		search := createAll(t, bound)[name]
+		assert.Equal(t, 2, search.AddBatch(entities), name)
+		assert.False(t, search.Contains(2), name)
+	}
+	// the IDs already in the search.
+	for _, name := range []string{"rtree", "crosslist"} {
+		search := createAll(t, bound)[name]
+		search.Add(entities[0])
+		assert.Equal(t, 2, search.AddBatch(entities), name)
+		assert.Equal(t, 3, search.Len(), name)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	entities := scatter(1000)
+	octree, err := BuildOctree(bound, 5, 8, entities, WithConcurrency())
+	assert.Nil(t, err)
+	quadtree, err := BuildQuadtree(bound, 5, 8, entities)
+	assert.Nil(t, err)
+	rtree := BuildRTree(consts.Dim3, 4, 16, entities)
+	for _, search := range []siface.ISearch{octree, quadtree, rtree} {
+		assert.Equal(t, 1000, search.Len())
+		assert.Len(t, search.GetEntitiesInBound(bound), 1000)
+	}
+	_, err = BuildOctree(bound, 0, 8, entities)
+	assert.NotNil(t, err)
+}
+
+const buildSize = 200000
+
+func benchmarkBuild(b *testing.B, create func() siface.ISearch, batch bool) {
+	entities := scatter(buildSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		search := create()
+		if batch {
+			search.AddBatch(entities)
+		} else {
+			for _, entity := range entities {
+				search.Add(entity)
+			}
+		}
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	creators := map[string]func() siface.ISearch{
+		"octree": func() siface.ISearch {
+			ret, _ := CreateOctree(bound, 8, 16)
+			return ret
+		},
+		"quadtree": func() siface.ISearch {
+			ret, _ := CreateQuadtree(bound, 8, 16)
+			return ret
+		},
+		"rtree": func() siface.ISearch {
+			return CreateRTree(consts.Dim3, 8, 32)
+		},
+		"crosslist": func() siface.ISearch {
+			ret, _ := CreateCrossList(consts.Dim3)
+			return ret
+		},
+	}
+	for _, name := range []string{"octree", "quadtree", "rtree", "crosslist"} {
+		b.Run(name+"/incremental", func(b *testing.B) {
+			if name == "crosslist" {
+				b.Skip("quadratic, see the batch")
+			}
+			benchmarkBuild(b, creators[name], false)
+		})
+		b.Run(name+"/batch", func(b *testing.B) {
+			benchmarkBuild(b, creators[name], true)
+		})
+	}
+}
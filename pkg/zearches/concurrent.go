@@ -0,0 +1,205 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+	"sync"
+)
+
+// concurrent guards a search tree with a read-write mutex,
+// queries share the read lock while mutations take the write lock.
+type concurrent struct {
+	mu     sync.RWMutex
+	origin siface.ISearch
+}
+
+// NewConcurrent wraps a search tree so that it's safe for concurrent use by multiple goroutines.
+//
+// The filters passed to the queries run under the read lock,
+// they must not mutate the search tree, or they will deadlock.
+func NewConcurrent(search siface.ISearch) siface.ISearch {
+	switch c := search.(type) {
+	case *concurrent, *concurrentMoveDiff, *concurrentNodes:
+		return c
+	}
+	if _, ok := search.(siface.IMoveDiff); ok {
+		return &concurrentMoveDiff{concurrent: concurrent{origin: search}}
+	}
+	if _, ok := search.(siface.INodes); ok {
+		return &concurrentNodes{concurrent: concurrent{origin: search}}
+	}
+	return &concurrent{origin: search}
+}
+
+// concurrentMoveDiff keeps siface.IMoveDiff of the wrapped search tree.
+type concurrentMoveDiff struct {
+	concurrent
+}
+
+func (c *concurrentMoveDiff) UpdateWithDiff(entity siface.ISpatial, oldLocation geo.Vec3Int, radius float32) ([]siface.ISpatial, []siface.ISpatial, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.(siface.IMoveDiff).UpdateWithDiff(entity, oldLocation, radius)
+}
+
+// concurrentNodes keeps siface.INodes of the wrapped search tree.
+type concurrentNodes struct {
+	concurrent
+}
+
+func (c *concurrentNodes) Nodes() iter.Seq[siface.NodeInfo] {
+	return func(yield func(info siface.NodeInfo) bool) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		c.origin.(siface.INodes).Nodes()(yield)
+	}
+}
+
+func (c *concurrent) Add(entity siface.ISpatial) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.Add(entity)
+}
+
+func (c *concurrent) AddE(entity siface.ISpatial) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.AddE(entity)
+}
+
+func (c *concurrent) AddBatch(entities []siface.ISpatial) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.AddBatch(entities)
+}
+
+func (c *concurrent) Remove(entityId int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.Remove(entityId)
+}
+
+func (c *concurrent) RemoveE(entityId int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.RemoveE(entityId)
+}
+
+func (c *concurrent) Get(entityId int64) (siface.ISpatial, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.Get(entityId)
+}
+
+func (c *concurrent) Contains(entityId int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.Contains(entityId)
+}
+
+func (c *concurrent) Update(entity siface.ISpatial, oldLocation geo.Vec3Int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.Update(entity, oldLocation)
+}
+
+func (c *concurrent) UpdateE(entity siface.ISpatial, oldLocation geo.Vec3Int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origin.UpdateE(entity, oldLocation)
+}
+
+func (c *concurrent) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetSurroundingEntities(center, radius, filters...)
+}
+
+func (c *concurrent) AppendSurroundingEntities(dst []siface.ISpatial, center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.AppendSurroundingEntities(dst, center, radius, filters...)
+}
+
+func (c *concurrent) VisitSurroundingEntities(center []float32, radius float32, visit func(entity siface.ISpatial) bool, filters ...func(entity siface.ISpatial) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.origin.VisitSurroundingEntities(center, radius, visit, filters...)
+}
+
+func (c *concurrent) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetSurroundingEntitiesWithMetric(center, radius, metric, filters...)
+}
+
+func (c *concurrent) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetNearest(center, k, maxDistance, filters...)
+}
+
+func (c *concurrent) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetEntitiesInBound(bound, filters...)
+}
+
+func (c *concurrent) GetIntersecting(bound bounds.Bound, filters ...func(entity siface.ISpatial) bool) []siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetIntersecting(bound, filters...)
+}
+
+func (c *concurrent) GetCollisionPairs() [][2]siface.ISpatial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.GetCollisionPairs()
+}
+
+// All holds the read lock during the loop, the loop body must not mutate the search tree.
+func (c *concurrent) All() iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		c.origin.All()(yield)
+	}
+}
+
+// Within holds the read lock during the loop, the loop body must not mutate the search tree.
+func (c *concurrent) Within(center []float32, radius float32, filters ...func(entity siface.ISpatial) bool) iter.Seq[siface.ISpatial] {
+	return func(yield func(entity siface.ISpatial) bool) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		c.origin.Within(center, radius, filters...)(yield)
+	}
+}
+
+func (c *concurrent) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.Len()
+}
+
+func (c *concurrent) Stats() siface.Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.Stats()
+}
+
+func (c *concurrent) Snapshot(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.Snapshot(w)
+}
+
+func (c *concurrent) ToDot() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origin.ToDot()
+}
@@ -0,0 +1,104 @@
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func concurrentTrees(t *testing.T) map[string]siface.ISearch {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	oct, err := CreateOctree(bound, 5, 8, WithConcurrency(), WithMergeIf(true))
+	assert.Nil(t, err)
+	quad, err := CreateQuadtree(bound, 5, 8, WithConcurrency())
+	assert.Nil(t, err)
+	grid, err := CreateGrid(bound, geo.NewVec3Int(50, 50, 50), WithConcurrency())
+	assert.Nil(t, err)
+	clist, err := CreateCrossList(consts.Dim3, WithConcurrency())
+	assert.Nil(t, err)
+	return map[string]siface.ISearch{
+		"octree":    oct,
+		"quadtree":  quad,
+		"rtree":     CreateRTree(consts.Dim3, 2, 8, WithConcurrency()),
+		"grid":      grid,
+		"crosslist": clist,
+	}
+}
+
+func TestConcurrent_Hammer(t *testing.T) {
+	const (
+		writers = 4
+		readers = 4
+		rounds  = 300
+	)
+	for name, search := range concurrentTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			wg := sync.WaitGroup{}
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					r := rand.New(rand.NewSource(int64(w)))
+					for i := 0; i < rounds; i++ {
+						// every writer owns its own IDs
+						id := int64(w*rounds + i)
+						entity := mocks.CreateMockSpatial(id, r.Int31n(1000), r.Int31n(1000), r.Int31n(1000))
+						assert.True(t, search.Add(entity))
+						moved := mocks.CreateMockSpatial(id, r.Int31n(1000), r.Int31n(1000), r.Int31n(1000))
+						assert.True(t, search.Update(moved, entity.GetLocation()))
+						if i%2 == 0 {
+							assert.True(t, search.Remove(id))
+						}
+					}
+				}(w)
+			}
+			for rd := 0; rd < readers; rd++ {
+				wg.Add(1)
+				go func(rd int) {
+					defer wg.Done()
+					r := rand.New(rand.NewSource(int64(100 + rd)))
+					for i := 0; i < rounds; i++ {
+						center := []float32{float32(r.Int31n(1000)), float32(r.Int31n(1000)), float32(r.Int31n(1000))}
+						search.GetSurroundingEntities(center, 100)
+						search.GetNearest(center, 5, 0)
+						search.Contains(int64(i))
+					}
+				}(rd)
+			}
+			wg.Wait()
+			for w := 0; w < writers; w++ {
+				for i := 0; i < rounds; i++ {
+					assert.Equal(t, i%2 == 1, search.Contains(int64(w*rounds+i)))
+				}
+			}
+		})
+	}
+}
+
+func TestNewConcurrent_Idempotent(t *testing.T) {
+	search := NewConcurrent(CreateRTree(consts.Dim3, 2, 8))
+	assert.Same(t, search, NewConcurrent(search))
+}
+
+func TestNewConcurrent_KeepsMoveDiff(t *testing.T) {
+	search, err := CreateCrossList(consts.Dim2, WithConcurrency())
+	assert.Nil(t, err)
+	assert.Same(t, search, NewConcurrent(search))
+	differ, ok := search.(siface.IMoveDiff)
+	assert.True(t, ok)
+	entity := mocks.CreateMockSpatial(1, 0, 0, 0)
+	search.Add(entity)
+	search.Add(mocks.CreateMockSpatial(2, 20, 0, 0))
+	enter, leave, ok := differ.UpdateWithDiff(mocks.CreateMockSpatial(1, 15, 0, 0), entity.GetLocation(), 10)
+	assert.True(t, ok)
+	assert.Len(t, enter, 1)
+	assert.Empty(t, leave)
+	_, ok = NewConcurrent(CreateRTree(consts.Dim3, 2, 8)).(siface.IMoveDiff)
+	assert.False(t, ok)
+}
@@ -0,0 +1,117 @@
+// Package zearches .
+package zearches
+
+import (
+	"bytes"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestDuplicates_NoOrphans(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	all := bounds.NewBound(geo.NewVec3Int(-1, -1, -1), geo.NewVec3Int(101, 101, 101))
+	for _, policy := range []consts.Duplicate{consts.DuplicateReject, consts.DuplicateReplace, consts.DuplicateUpdate} {
+		for name, search := range createAll(t, bound, WithDuplicate(policy)) {
+			r := rand.New(rand.NewSource(int64(policy)))
+			first, last := map[int64]geo.Vec3Int{}, map[int64]geo.Vec3Int{}
+			add := func(entity siface.ISpatial) {
+				if _, ok := first[entity.GetID()]; !ok {
+					first[entity.GetID()] = entity.GetLocation()
+				}
+				last[entity.GetID()] = entity.GetLocation()
+			}
+			for i := 0; i < 200; i++ {
+				entity := mocks.CreateMockSpatial(int64(i%20), r.Int31n(100), r.Int31n(100), r.Int31n(100))
+				add(entity)
+				err := search.AddE(entity)
+				if policy == consts.DuplicateReject && i >= 20 {
+					assert.ErrorIs(t, err, siface.ErrDuplicateID, name)
+				} else {
+					assert.Nil(t, err, name)
+				}
+			}
+			batch := make([]siface.ISpatial, 0, 60)
+			for i := 0; i < 60; i++ {
+				entity := mocks.CreateMockSpatial(int64(i%30), r.Int31n(100), r.Int31n(100), r.Int31n(100))
+				add(entity)
+				batch = append(batch, entity)
+			}
+			// the 10 new IDs are always added, the rest by the policy.
+			if policy == consts.DuplicateReject {
+				assert.Equal(t, 10, search.AddBatch(batch), name)
+			} else {
+				assert.Equal(t, 60, search.AddBatch(batch), name)
+			}
+
+			want := first
+			if policy != consts.DuplicateReject {
+				want = last
+			}
+			for id, location := range want {
+				entity, ok := search.Get(id)
+				assert.True(t, ok, name)
+				assert.Equal(t, location, entity.GetLocation(), name)
+				assert.Equal(t, []int64{id}, sortedIds(search.GetEntitiesInBound(bounds.NewBound(location, location), func(e siface.ISpatial) bool {
+					return e.GetID() == id
+				})), name)
+			}
+			assert.Equal(t, 30, search.Len(), name)
+			assert.Equal(t, 30, search.Stats().Entities, name)
+			assert.Equal(t, 30, len(search.GetEntitiesInBound(all)), name)
+
+			// nothing is left behind once each ID is removed.
+			for id := range want {
+				assert.Nil(t, search.RemoveE(id), name)
+			}
+			assert.Equal(t, 0, search.Len(), name)
+			assert.Equal(t, 0, search.Stats().Entities, name)
+			assert.Empty(t, search.GetEntitiesInBound(all), name)
+			assert.Empty(t, search.GetSurroundingEntities([]float32{50, 50, 50}, 1000), name)
+			assert.Empty(t, search.GetNearest([]float32{50, 50, 50}, 10, 0), name)
+		}
+	}
+}
+
+func TestDuplicates_Rejected(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for _, policy := range []consts.Duplicate{consts.DuplicateReplace, consts.DuplicateUpdate} {
+		for name, search := range createAll(t, bound, WithDuplicate(policy)) {
+			assert.Nil(t, search.AddE(mocks.CreateMockSpatial(1, 50, 50, 50)), name)
+			// an invalid bound is rejected before the policy.
+			invalid := mocks.CreateMockSpatial(1, 10, 10, 10, bounds.NewBound(geo.NewVec3Int(20, 20, 20), geo.NewVec3Int(0, 0, 0)))
+			assert.ErrorIs(t, search.AddE(invalid), siface.ErrInvalidBound, name)
+			if name != "rtree" && name != "crosslist" {
+				// the entity held stays if the new one is out of bounds.
+				assert.ErrorIs(t, search.AddE(mocks.CreateMockSpatial(1, 500, 50, 50)), siface.ErrOutOfBounds, name)
+			}
+			entity, ok := search.Get(1)
+			assert.True(t, ok, name)
+			assert.Equal(t, geo.NewVec3Int(50, 50, 50), entity.GetLocation(), name)
+			assert.Equal(t, 1, search.Len(), name)
+			assert.Equal(t, []int64{1}, sortedIds(search.GetSurroundingEntities([]float32{50, 50, 50}, 1)), name)
+		}
+	}
+}
+
+func TestDuplicates_Restore(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree := must(CreateOctree(bound, 3, 2))
+	octree.Add(mocks.CreateMockSpatial(1, 50, 50, 50))
+	var buf bytes.Buffer
+	assert.Nil(t, octree.Snapshot(&buf))
+	restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+		entity, _ := octree.Get(id)
+		return entity
+	}, WithDuplicate(consts.DuplicateUpdate))
+	assert.Nil(t, err)
+	// the policy isn't persisted, it's set on restore.
+	assert.True(t, restored.Add(mocks.CreateMockSpatial(1, 10, 10, 10)))
+	assert.Equal(t, []int64{1}, sortedIds(restored.GetSurroundingEntities([]float32{10, 10, 10}, 1)))
+	assert.Equal(t, 1, restored.Len())
+}
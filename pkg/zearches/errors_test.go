@@ -0,0 +1,66 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestErrors_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	inverted := mocks.CreateMockSpatial(2, 50, 50, 50, bounds.NewBound(geo.NewVec3Int(60, 40, 40), geo.NewVec3Int(40, 60, 60)))
+	partial := mocks.CreateMockSpatial(3, 50, 50, 50, bounds.Bound{Min: geo.NewVec3Int(40, 40, 40)})
+	for name, search := range createAll(t, bound) {
+		for _, s := range []siface.ISearch{search, NewConcurrent(search)} {
+			assert.Nil(t, s.AddE(mocks.CreateMockSpatial(1, 50, 50, 50)), name)
+			assert.ErrorIs(t, s.AddE(mocks.CreateMockSpatial(1, 10, 10, 10)), siface.ErrDuplicateID, name)
+			assert.False(t, s.Add(mocks.CreateMockSpatial(1, 10, 10, 10)), name)
+			assert.ErrorIs(t, s.AddE(inverted), siface.ErrInvalidBound, name)
+			assert.ErrorIs(t, s.AddE(partial), siface.ErrInvalidBound, name)
+			assert.ErrorIs(t, s.UpdateE(mocks.CreateMockSpatial(1, 50, 50, 50, inverted.GetBound()), geo.NewVec3Int(50, 50, 50)), siface.ErrInvalidBound, name)
+			assert.ErrorIs(t, s.UpdateE(mocks.CreateMockSpatial(4, 50, 50, 50), geo.NewVec3Int(50, 50, 50)), siface.ErrNotFound, name)
+			assert.ErrorIs(t, s.RemoveE(4), siface.ErrNotFound, name)
+			// the duplicates and the invalid ones are skipped in a batch.
+			assert.Equal(t, 1, s.AddBatch([]siface.ISpatial{mocks.CreateMockSpatial(1, 10, 10, 10), inverted, mocks.CreateMockSpatial(5, 10, 10, 10), mocks.CreateMockSpatial(5, 20, 20, 20)}), name)
+			assert.Equal(t, []int64{1, 5}, sortedIds(s.GetSurroundingEntities([]float32{0, 0, 0}, 1000)), name)
+			entity, _ := s.Get(1)
+			assert.Equal(t, geo.NewVec3Int(50, 50, 50), entity.GetLocation(), name)
+
+			assert.Nil(t, s.RemoveE(1), name)
+			assert.Nil(t, s.RemoveE(5), name)
+			assert.Equal(t, 0, s.Len(), name)
+		}
+	}
+}
+
+func TestErrors_OutOfBounds(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for name, search := range createAll(t, bound) {
+		if name == "rtree" || name == "crosslist" {
+			continue // unbounded.
+		}
+		assert.ErrorIs(t, search.AddE(mocks.CreateMockSpatial(1, 150, 50, 50)), siface.ErrOutOfBounds, name)
+		assert.Nil(t, search.AddE(mocks.CreateMockSpatial(2, 50, 50, 50)), name)
+		assert.ErrorIs(t, search.UpdateE(mocks.CreateMockSpatial(2, -50, 50, 50), geo.NewVec3Int(50, 50, 50)), siface.ErrOutOfBounds, name)
+		entity, _ := search.Get(2)
+		assert.Equal(t, geo.NewVec3Int(50, 50, 50), entity.GetLocation(), name)
+		assert.Equal(t, 1, search.Len(), name)
+	}
+}
+
+func TestErrors_Typed(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	search, err := CreateOctreeOf[*player](bound, 3, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, search.AddE(newPlayer(1, 10, 10, 10, "a")))
+	assert.ErrorIs(t, search.AddE(newPlayer(1, 20, 20, 20, "b")), siface.ErrDuplicateID)
+	assert.ErrorIs(t, search.AddE(newPlayer(2, 200, 20, 20, "c")), siface.ErrOutOfBounds)
+	assert.ErrorIs(t, search.UpdateE(newPlayer(3, 20, 20, 20, "d"), geo.NewVec3Int(20, 20, 20)), siface.ErrNotFound)
+	assert.ErrorIs(t, search.RemoveE(3), siface.ErrNotFound)
+	assert.Nil(t, search.UpdateE(newPlayer(1, 20, 20, 20, "a"), geo.NewVec3Int(10, 10, 10)))
+	assert.Nil(t, search.RemoveE(1))
+}
@@ -1,8 +1,10 @@
-// Package zearches provides functions to create and manage spatial partitioning structures like octrees and quadtrees, etc.
+// Package zearches provides functions to create and manage spatial partitioning structures like octrees, quadtrees and grids, etc.
 package zearches
 
 import (
 	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/crosslist"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/grid"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/octree"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
 	"github.com/cozmo-zh/zearches/internal/pkg/tree/quadtree"
@@ -10,13 +12,20 @@ import (
 	"github.com/cozmo-zh/zearches/pkg/bounds"
 	"github.com/cozmo-zh/zearches/pkg/geo"
 	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
 )
 
 // OptionalSettings holds configuration options for creating spatial trees.
 type OptionalSettings struct {
-	MergeIf   bool                          // Flag to determine if nodes should be merged when removing an entity.
-	ScaleFunc func(v []float32) geo.Vec3Int // Function to scale float32 slice to geo.Vec3Int.
-	path      string
+	MergeIf    bool                          // Flag to determine if nodes should be merged when removing an entity.
+	ScaleFunc  func(v []float32) geo.Vec3Int // Function to scale float32 slice to geo.Vec3Int, the elements are truncated if it's nil.
+	path       string
+	concurrent bool
+	plane      consts.Plane
+	metric     util.DistanceMetric
+	loose      float64
+	outside    consts.OutOfBounds
+	duplicate  consts.Duplicate
 }
 
 // Option is a function type used to configure OptionalSettings.
@@ -46,6 +55,87 @@ func WithDrawPath(path string) Option {
 	}
 }
 
+// WithPlane sets the plane of a quadtree, the other axis is ignored.
+// Parameters:
+// - plane: consts.PlaneXZ(default, y is the altitude) or consts.PlaneXY(for top-down or tile games).
+func WithPlane(plane consts.Plane) Option {
+	return func(s *OptionalSettings) {
+		s.plane = plane
+	}
+}
+
+// WithMetric sets the distance metric of the surrounding queries, the euclidean distance by default.
+// The metrics take y as the vertical axis, on a quadtree of consts.PlaneXY they measure x and y as x and z.
+// Parameters:
+// - metric: like util.Chebyshev{} for a square vision, util.Manhattan{}, or util.Cylinder{HalfHeight: h} for flying units.
+func WithMetric(metric util.DistanceMetric) Option {
+	return func(s *OptionalSettings) {
+		s.metric = metric
+	}
+}
+
+// WithLooseFactor makes an octree or a quadtree loose, the entities are indexed by their bounds instead of their locations,
+// so the sized entities straddling the nodes are found by the queries measuring their bounds, like the rtree.
+// A node holds the entities whose bound fits in its bound widened by (k-1)/2 of its size on each side,
+// the ones fitting no child stay in the node.
+// Parameters:
+// - k: the loose factor, at least 1, 2 is usual. The larger, the fewer entities stay in the internal nodes but the more nodes a query visits.
+func WithLooseFactor(k float64) Option {
+	return func(s *OptionalSettings) {
+		s.loose = k
+	}
+}
+
+// WithSticky makes an octree or a quadtree keep the entities whose bound doesn't fit in a single child in the parent node,
+// instead of the child holding their location. It's WithLooseFactor(1), the nodes aren't widened.
+func WithSticky() Option {
+	return WithLooseFactor(1)
+}
+
+// WithOutOfBounds sets the policy of an octree or a quadtree for the entities added or moved out of its bound.
+// Parameters:
+// - policy: consts.OutOfBoundsReject(default, Add and Update return false), consts.OutOfBoundsExpand(see WithAutoExpand),
+// consts.OutOfBoundsClamp(held by the node at the nearest edge, the queries clamped into the bound find them there),
+// or consts.OutOfBoundsOverflow(held by the root as an overflow bucket, every query consults it).
+func WithOutOfBounds(policy consts.OutOfBounds) Option {
+	return func(s *OptionalSettings) {
+		s.outside = policy
+	}
+}
+
+// WithAutoExpand makes an octree or a quadtree grow when an entity is added or moved out of its bound,
+// a new root doubling the bound toward the entity is created until it fits, the old root becomes one of its children.
+// The tree gets one level deeper each time, so the nodes keep their sizes. It's WithOutOfBounds(consts.OutOfBoundsExpand).
+func WithAutoExpand() Option {
+	return WithOutOfBounds(consts.OutOfBoundsExpand)
+}
+
+// WithDuplicate sets the policy of any tree for adding an entity whose ID is in it, by Add, AddE or AddBatch.
+// Parameters:
+// - policy: consts.DuplicateReject(default, AddE returns siface.ErrDuplicateID),
+// consts.DuplicateReplace(the entity held is removed, then the entity is added as a new one),
+// or consts.DuplicateUpdate(the entity is moved from the location of the entity held, like Update).
+func WithDuplicate(policy consts.Duplicate) Option {
+	return func(s *OptionalSettings) {
+		s.duplicate = policy
+	}
+}
+
+// WithConcurrency makes the tree safe for concurrent use by multiple goroutines, see NewConcurrent.
+func WithConcurrency() Option {
+	return func(s *OptionalSettings) {
+		s.concurrent = true
+	}
+}
+
+// wrap applies the settings which wrap the created tree.
+func (s *OptionalSettings) wrap(search siface.ISearch) siface.ISearch {
+	if s.concurrent {
+		return NewConcurrent(search)
+	}
+	return search
+}
+
 // CreateOctree creates a new Octree with the specified parameters.
 // Parameters:
 // - bound: the spatial boundaries of the tree.
@@ -54,11 +144,7 @@ func WithDrawPath(path string) Option {
 // - opt: variadic optional parameters to configure the octree.
 // Returns an ISpatial search interface and an error if creation fails.
 func CreateOctree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (siface.ISearch, error) {
-	s := &OptionalSettings{
-		ScaleFunc: func(v []float32) geo.Vec3Int {
-			return geo.NewVec3Int(int32(v[0]), int32(v[1]), int32(v[2]))
-		},
-	}
+	s := &OptionalSettings{}
 	for _, o := range opt {
 		o(s)
 	}
@@ -69,8 +155,12 @@ func CreateOctree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (si
 		option.WithMergeIf(s.MergeIf),
 		option.WithScale(s.ScaleFunc),
 		option.WithDrawPath(s.path),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+		option.WithLoose(s.loose),
+		option.WithOutOfBounds(s.outside),
 	); err == nil {
-		return ot, nil
+		return s.wrap(ot), nil
 	} else {
 		return nil, err
 	}
@@ -84,11 +174,7 @@ func CreateOctree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (si
 // - opt: variadic optional parameters to configure the quadtree.
 // Returns an ISpatial search interface and an error if creation fails.
 func CreateQuadtree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (siface.ISearch, error) {
-	s := &OptionalSettings{
-		ScaleFunc: func(v []float32) geo.Vec3Int {
-			return geo.NewVec3Int(int32(v[0]), int32(v[1]), int32(v[2]))
-		},
-	}
+	s := &OptionalSettings{}
 	for _, op := range opt {
 		op(s)
 	}
@@ -99,8 +185,62 @@ func CreateQuadtree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (
 		option.WithMergeIf(s.MergeIf),
 		option.WithScale(s.ScaleFunc),
 		option.WithDrawPath(s.path),
+		option.WithPlane(s.plane),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+		option.WithLoose(s.loose),
+		option.WithOutOfBounds(s.outside),
 	); err == nil {
-		return qt, nil
+		return s.wrap(qt), nil
+	} else {
+		return nil, err
+	}
+}
+
+// CreateGrid creates a new uniform Grid with the specified parameters.
+// Parameters:
+// - bound: the spatial boundaries of the grid.
+// - cellSize: the size of the cells, the grid is 2D(x/z, like a quadtree) if the y of cellSize is not positive.
+// - opt: variadic optional parameters to configure the grid, WithMergeIf, WithLooseFactor and WithOutOfBounds don't apply.
+// Returns an ISpatial search interface and an error if creation fails.
+func CreateGrid(bound bounds.Bound, cellSize geo.Vec3Int, opt ...Option) (siface.ISearch, error) {
+	s := &OptionalSettings{}
+	for _, op := range opt {
+		op(s)
+	}
+	if g, err := grid.NewGrid(
+		bound,
+		cellSize,
+		option.WithScale(s.ScaleFunc),
+		option.WithDrawPath(s.path),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+	); err == nil {
+		return s.wrap(g), nil
+	} else {
+		return nil, err
+	}
+}
+
+// CreateCrossList creates a new cross-linked list with the specified parameters.
+// The returned search also implements siface.IMoveDiff, to detect the entities entering and leaving on move.
+// Parameters:
+// - dim: the dimension of the list, a 2D list ignores y.
+// - opt: variadic optional parameters to configure the list, WithMergeIf, WithLooseFactor and WithOutOfBounds don't apply.
+// Returns an ISpatial search interface and an error if creation fails.
+func CreateCrossList(dim consts.Dim, opt ...Option) (siface.ISearch, error) {
+	s := &OptionalSettings{}
+	for _, op := range opt {
+		op(s)
+	}
+	if c, err := crosslist.NewCrossList(
+		dim,
+		option.WithScale(s.ScaleFunc),
+		option.WithDrawPath(s.path),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+	); err == nil {
+		return s.wrap(c), nil
 	} else {
 		return nil, err
 	}
@@ -110,6 +250,68 @@ func CreateQuadtree(bound bounds.Bound, maxDepth, capacity int, opt ...Option) (
 // Parameters:
 // - dim: the number of dimensions of the tree.
 // - min/max specify the minimum/maximum branching factors.
-func CreateRTree(dim consts.Dim, min, max int) siface.ISearch {
-	return rtree.NewRTree(dim, min, max)
+// - opt: variadic optional parameters to configure the rtree, WithMergeIf, WithPlane, WithLooseFactor and WithOutOfBounds don't apply.
+func CreateRTree(dim consts.Dim, min, max int, opt ...Option) siface.ISearch {
+	s := &OptionalSettings{}
+	for _, o := range opt {
+		o(s)
+	}
+	return s.wrap(rtree.NewRTree(
+		dim,
+		min,
+		max,
+		option.WithScale(s.ScaleFunc),
+		option.WithDrawPath(s.path),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+	))
+}
+
+// BuildOctree creates a new Octree like CreateOctree, and adds the entities top-down in one pass,
+// it's much faster than adding them one by one to populate a scene.
+// Parameters:
+// - bound: the spatial boundaries of the tree.
+// - maxDepth: the maximum depth of the tree.
+// - capacity: the maximum number of entities that a node can hold.
+// - entities: the entities to be added, the ones out of the bound are added by the policy, see WithOutOfBounds.
+// - opt: variadic optional parameters to configure the octree.
+// Returns an ISpatial search interface and an error if creation fails.
+func BuildOctree(bound bounds.Bound, maxDepth, capacity int, entities []siface.ISpatial, opt ...Option) (siface.ISearch, error) {
+	if ot, err := CreateOctree(bound, maxDepth, capacity, opt...); err == nil {
+		ot.AddBatch(entities)
+		return ot, nil
+	} else {
+		return nil, err
+	}
+}
+
+// BuildQuadtree creates a new QuadTree like CreateQuadtree, and adds the entities top-down in one pass,
+// it's much faster than adding them one by one to populate a scene.
+// Parameters:
+// - bound: the spatial boundaries of the tree.
+// - maxDepth: the maximum depth of the tree.
+// - capacity: the maximum number of entities that a node can hold.
+// - entities: the entities to be added, the ones out of the bound are added by the policy, see WithOutOfBounds.
+// - opt: variadic optional parameters to configure the quadtree.
+// Returns an ISpatial search interface and an error if creation fails.
+func BuildQuadtree(bound bounds.Bound, maxDepth, capacity int, entities []siface.ISpatial, opt ...Option) (siface.ISearch, error) {
+	if qt, err := CreateQuadtree(bound, maxDepth, capacity, opt...); err == nil {
+		qt.AddBatch(entities)
+		return qt, nil
+	} else {
+		return nil, err
+	}
+}
+
+// BuildRTree creates a new RTree like CreateRTree, and bulk loads the entities,
+// the tree is packed top-down instead of splitting nodes on each insertion.
+// Parameters:
+// - dim: the number of dimensions of the tree.
+// - min/max specify the minimum/maximum branching factors.
+// - entities: the entities to be added.
+// - opt: variadic optional parameters to configure the rtree, WithMergeIf, WithPlane, WithLooseFactor and WithOutOfBounds don't apply.
+func BuildRTree(dim consts.Dim, min, max int, entities []siface.ISpatial, opt ...Option) siface.ISearch {
+	rt := CreateRTree(dim, min, max, opt...)
+	rt.AddBatch(entities)
+	return rt
 }
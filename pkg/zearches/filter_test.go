@@ -0,0 +1,67 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/filter"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// createAll creates one search of each kind over the bound, with the options applying to all of them.
+func createAll(t *testing.T, bound bounds.Bound, opt ...Option) map[string]siface.ISearch {
+	octree, err := CreateOctree(bound, 3, 2, opt...)
+	assert.Nil(t, err)
+	quadtree, err := CreateQuadtree(bound, 3, 2, opt...)
+	assert.Nil(t, err)
+	grid, err := CreateGrid(bound, geo.NewVec3Int(10, 10, 10), opt...)
+	assert.Nil(t, err)
+	crosslist, err := CreateCrossList(consts.Dim3, opt...)
+	assert.Nil(t, err)
+	return map[string]siface.ISearch{
+		"octree":    octree,
+		"quadtree":  quadtree,
+		"rtree":     CreateRTree(consts.Dim3, 2, 8, opt...),
+		"grid":      grid,
+		"crosslist": crosslist,
+	}
+}
+
+func TestFilters_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	even := func(entity siface.ISpatial) bool { return entity.GetID()%2 == 0 }
+	small := func(entity siface.ISpatial) bool { return entity.GetID() < 6 }
+	ids := func(entities []siface.ISpatial) []int64 {
+		ret := make([]int64, 0, len(entities))
+		for _, entity := range entities {
+			ret = append(ret, entity.GetID())
+		}
+		return ret
+	}
+	cases := []struct {
+		name     string
+		filters  []filter.Filter
+		expected []int64
+	}{
+		{"none", nil, []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"and", []filter.Filter{even, small}, []int64{2, 4}},
+		{"any", []filter.Filter{filter.Any(even, small)}, []int64{1, 2, 3, 4, 5, 6, 8}},
+		{"not", []filter.Filter{filter.Not(even), filter.ExcludeID(3)}, []int64{1, 5, 7}},
+		{"ids", []filter.Filter{filter.ByIDs(1, 2, 3), filter.Not(filter.ByIDs(2))}, []int64{1, 3}},
+	}
+	for name, search := range createAll(t, bound) {
+		for i := int64(1); i <= 8; i++ {
+			search.Add(mocks.CreateMockSpatial(i, int32(40+i), 50, int32(50-i)))
+		}
+		for _, c := range cases {
+			assert.ElementsMatch(t, c.expected, ids(search.GetSurroundingEntities([]float32{45, 50, 45}, 20, c.filters...)), name+"/"+c.name)
+			assert.ElementsMatch(t, c.expected, ids(search.GetEntitiesInBound(bound, c.filters...)), name+"/"+c.name)
+			assert.ElementsMatch(t, c.expected, ids(search.GetIntersecting(bound, c.filters...)), name+"/"+c.name)
+			assert.ElementsMatch(t, c.expected, ids(search.GetNearest([]float32{45, 50, 45}, 8, 0, c.filters...)), name+"/"+c.name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := createAll(t, bound)
+	populate(searches, 500)
+	center := []float32{500, 500, 500}
+	for name, search := range searches {
+		ids := make(map[int64]bool)
+		for entity := range search.All() {
+			ids[entity.GetID()] = true
+		}
+		assert.Len(t, ids, 500, name)
+
+		within := make([]siface.ISpatial, 0)
+		for entity := range search.Within(center, 200) {
+			within = append(within, entity)
+		}
+		assert.ElementsMatch(t, search.GetSurroundingEntities(center, 200), within, name)
+
+		n := 0
+		for range search.Within(center, 200) {
+			if n++; n == 2 {
+				break
+			}
+		}
+		assert.Equal(t, 2, n, name)
+	}
+}
+
+func TestIterators_Nodes(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	octree, _ := CreateOctree(bound, 4, 8, WithConcurrency())
+	quadtree, _ := CreateQuadtree(bound, 4, 8)
+	searches := map[string]siface.ISearch{"octree": octree, "quadtree": quadtree}
+	populate(searches, 500)
+	for name, search := range searches {
+		nodes, ok := search.(siface.INodes)
+		assert.True(t, ok, name)
+		total, maxDepth, first := 0, 0, true
+		for info := range nodes.Nodes() {
+			if first {
+				assert.Equal(t, 0, info.Depth, name)
+				assert.Equal(t, bound.Min, info.Bound.Min, name)
+				first = false
+			}
+			total += info.Count
+			maxDepth = max(maxDepth, info.Depth)
+		}
+		assert.Equal(t, 500, total, name)
+		assert.Equal(t, 3, maxDepth, name)
+	}
+}
@@ -0,0 +1,168 @@
+// Package zearches .
+package zearches
+
+import (
+	"bytes"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// sized returns an entity at the location whose bound reaches size/2 around it.
+func sized(id int64, x, y, z, size int32) siface.ISpatial {
+	half := size / 2
+	return mocks.CreateMockSpatial(id, x, y, z, bounds.NewBound(geo.NewVec3Int(x-half, y-half, z-half), geo.NewVec3Int(x+half, y+half, z+half)))
+}
+
+// randomSized returns an entity within the bound of size 1000, a third of them are points and a few of them are large.
+func randomSized(r *rand.Rand, id int64) siface.ISpatial {
+	x, y, z := 100+r.Int31n(800), 100+r.Int31n(800), 100+r.Int31n(800)
+	switch id % 6 {
+	case 0, 1:
+		return mocks.CreateMockSpatial(id, x, y, z)
+	case 2:
+		return sized(id, x, y, z, 100+r.Int31n(100))
+	default:
+		return sized(id, x, y, z, r.Int31n(30))
+	}
+}
+
+func TestLoose_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	for _, k := range []float64{1, 1.5, 2} {
+		octree, err := CreateOctree(bound, 5, 4, WithLooseFactor(k))
+		assert.Nil(t, err)
+		quadtree, err := CreateQuadtree(bound, 5, 4, WithLooseFactor(k))
+		assert.Nil(t, err)
+		// the rtree indexes the bounds as well.
+		cases := []struct {
+			search    siface.ISearch
+			reference siface.ISearch
+		}{
+			{octree, CreateRTree(consts.Dim3, 2, 8)},
+			{quadtree, CreateRTree(consts.Dim2, 2, 8)},
+		}
+		r := rand.New(rand.NewSource(int64(k * 10)))
+		for i := int64(0); i < 600; i++ {
+			entity := randomSized(r, i)
+			for _, c := range cases {
+				assert.True(t, c.search.Add(entity))
+				c.reference.Add(entity)
+			}
+		}
+		for i := int64(0); i < 300; i++ {
+			for _, c := range cases {
+				if i%3 == 0 {
+					assert.True(t, c.search.Remove(i))
+					c.reference.Remove(i)
+					continue
+				}
+				old, _ := c.search.Get(i)
+				moved := randomSized(r, i)
+				assert.True(t, c.search.Update(moved, old.GetLocation()))
+				c.reference.Update(moved, old.GetLocation())
+			}
+		}
+		for _, c := range cases {
+			for _, center := range [][]float32{{500, 500, 500}, {120, 880, 300}, {950, 50, 950}} {
+				box := bounds.NewBound(
+					geo.NewVec3Int(int32(center[0])-80, int32(center[1])-80, int32(center[2])-80),
+					geo.NewVec3Int(int32(center[0])+80, int32(center[1])+80, int32(center[2])+80))
+				assert.Equal(t, sortedIds(c.reference.GetSurroundingEntities(center, 90)), sortedIds(c.search.GetSurroundingEntities(center, 90)), k)
+				assert.Equal(t, sortedIds(c.reference.GetSurroundingEntitiesWithMetric(center, 90, util.Chebyshev{})), sortedIds(c.search.GetSurroundingEntitiesWithMetric(center, 90, util.Chebyshev{})), k)
+				assert.Equal(t, sortedIds(c.reference.GetEntitiesInBound(box)), sortedIds(c.search.GetEntitiesInBound(box)), k)
+				assert.Equal(t, sortedIds(c.reference.GetIntersecting(box)), sortedIds(c.search.GetIntersecting(box)), k)
+				assert.Equal(t, sortedIds(c.reference.GetNearest(center, 1000, 120)), sortedIds(c.search.GetNearest(center, 1000, 120)), k)
+			}
+			assert.Equal(t, c.reference.Len(), c.search.Len(), k)
+			assert.Equal(t, c.reference.Len(), c.search.Stats().Entities, k)
+		}
+	}
+}
+
+func TestLoose_Straddling(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	boss := sized(1, 45, 45, 45, 30)
+	box := bounds.NewBound(geo.NewVec3Int(55, 55, 55), geo.NewVec3Int(70, 70, 70))
+	loose, err := CreateOctree(bound, 3, 1, WithLooseFactor(2))
+	assert.Nil(t, err)
+	tight, err := CreateOctree(bound, 3, 1)
+	assert.Nil(t, err)
+	for _, search := range []siface.ISearch{loose, tight} {
+		search.Add(mocks.CreateMockSpatial(2, 10, 10, 10))
+		search.Add(mocks.CreateMockSpatial(3, 90, 90, 90))
+		search.Add(boss)
+	}
+	// the box doesn't cover the location of the boss.
+	assert.Equal(t, []siface.ISpatial{boss}, loose.GetEntitiesInBound(box))
+	assert.Empty(t, tight.GetEntitiesInBound(box))
+	assert.Equal(t, []siface.ISpatial{boss}, loose.GetSurroundingEntities([]float32{65, 65, 65}, 10))
+	assert.Empty(t, tight.GetSurroundingEntities([]float32{65, 65, 65}, 10))
+
+	_, err = CreateOctree(bound, 3, 1, WithLooseFactor(0.5))
+	assert.NotNil(t, err)
+	_, err = CreateQuadtree(bound, 3, 1, WithLooseFactor(-1))
+	assert.NotNil(t, err)
+}
+
+func TestLoose_Edge(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	// located within the bound, its bound straddles the edge of the root.
+	edge := mocks.CreateMockSpatial(1, 1, 50, 50, bounds.NewBound(geo.NewVec3Int(-3, 45, 45), geo.NewVec3Int(5, 55, 55)))
+	box := bounds.NewBound(geo.NewVec3Int(-10, 40, 40), geo.NewVec3Int(-1, 60, 60))
+	for _, k := range []float64{1, 2} {
+		octree := must(CreateOctree(bound, 3, 1, WithLooseFactor(k)))
+		batch := must(BuildOctree(bound, 3, 1, []siface.ISpatial{mocks.CreateMockSpatial(2, 90, 90, 90), edge}, WithLooseFactor(k)))
+		assert.True(t, octree.Add(mocks.CreateMockSpatial(2, 90, 90, 90)))
+		assert.True(t, octree.Add(edge), k)
+		for _, search := range []siface.ISearch{octree, batch} {
+			assert.Equal(t, 2, search.Len(), k)
+			assert.Equal(t, []siface.ISpatial{edge}, search.GetIntersecting(box), k)
+			assert.Equal(t, []siface.ISpatial{edge}, search.GetSurroundingEntities([]float32{-5, 50, 50}, 2), k)
+		}
+		// moved to the other edge.
+		moved := mocks.CreateMockSpatial(1, 99, 50, 50, bounds.NewBound(geo.NewVec3Int(95, 45, 45), geo.NewVec3Int(103, 55, 55)))
+		assert.True(t, octree.Update(moved, edge.GetLocation()), k)
+		assert.Empty(t, octree.GetIntersecting(box), k)
+		assert.Equal(t, []siface.ISpatial{moved}, octree.GetSurroundingEntities([]float32{105, 50, 50}, 2), k)
+		assert.True(t, octree.Remove(1), k)
+	}
+}
+
+func TestLoose_BatchAndSnapshot(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	r := rand.New(rand.NewSource(5))
+	entities := make([]siface.ISpatial, 0)
+	for i := int64(0); i < 1000; i++ {
+		entities = append(entities, randomSized(r, i))
+	}
+	incremental, err := CreateQuadtree(bound, 5, 4, WithLooseFactor(1))
+	assert.Nil(t, err)
+	for _, entity := range entities {
+		incremental.Add(entity)
+	}
+	batch, err := BuildQuadtree(bound, 5, 4, entities, WithLooseFactor(1))
+	assert.Nil(t, err)
+	assert.Equal(t, nodesOf(incremental), nodesOf(batch))
+
+	var buf bytes.Buffer
+	assert.Nil(t, batch.Snapshot(&buf))
+	restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+		entity, _ := batch.Get(id)
+		return entity
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, nodesOf(batch), nodesOf(restored))
+	// still loose, the sized entities stay in the internal nodes.
+	box := bounds.NewBound(geo.NewVec3Int(400, 0, 400), geo.NewVec3Int(600, 0, 600))
+	assert.Equal(t, sortedIds(batch.GetEntitiesInBound(box)), sortedIds(restored.GetEntitiesInBound(box)))
+	assert.Greater(t, len(restored.GetEntitiesInBound(box)), len(restored.GetEntitiesInBound(box, func(entity siface.ISpatial) bool {
+		return box.ContainsAxes(entity.GetLocation(), 0, 2)
+	})))
+}
@@ -0,0 +1,58 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMetrics_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	center := []float32{50, 50, 50}
+	entities := make([]siface.ISpatial, 0)
+	for x := int32(0); x <= 100; x += 5 {
+		for z := int32(0); z <= 100; z += 5 {
+			entities = append(entities, mocks.CreateMockSpatial(int64(len(entities)+1), x, 50, z))
+		}
+	}
+	for _, metric := range []util.DistanceMetric{util.Euclidean2D{}, util.Euclidean3D{}, util.Manhattan{}, util.Chebyshev{}, util.Cylinder{HalfHeight: 5}} {
+		expected := make([]siface.ISpatial, 0)
+		for _, entity := range entities {
+			if metric.Within(entity.GetLocation().ToFloat32(), center, 12) {
+				expected = append(expected, entity)
+			}
+		}
+		for name, search := range createAll(t, bound) {
+			for _, entity := range entities {
+				search.Add(entity)
+			}
+			ret := search.GetSurroundingEntitiesWithMetric(center, 12, metric)
+			assert.ElementsMatch(t, expected, ret, "%s %T", name, metric)
+		}
+	}
+}
+
+func TestWithMetric_Cylinder(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, err := CreateOctree(bound, 3, 2, WithMetric(util.Cylinder{HalfHeight: 5}))
+	assert.Nil(t, err)
+	grid, err := CreateGrid(bound, geo.NewVec3Int(10, 10, 10), WithMetric(util.Cylinder{HalfHeight: 5}))
+	assert.Nil(t, err)
+	rtree := CreateRTree(consts.Dim3, 2, 8, WithMetric(util.Cylinder{HalfHeight: 5}))
+	for name, search := range map[string]siface.ISearch{"octree": octree, "grid": grid, "rtree": rtree} {
+		low := mocks.CreateMockSpatial(1, 50, 46, 58)
+		high := mocks.CreateMockSpatial(2, 50, 80, 50)
+		far := mocks.CreateMockSpatial(3, 61, 50, 50)
+		search.Add(low)
+		search.Add(high)
+		search.Add(far)
+		// the band of y is narrower than the radius
+		assert.Equal(t, []siface.ISpatial{low}, search.GetSurroundingEntities([]float32{50, 50, 50}, 10), name)
+	}
+}
@@ -0,0 +1,186 @@
+// Package zearches .
+package zearches
+
+import (
+	"bytes"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// wandering returns an entity within the bound from -1000 to 2000, most of them are out of the bound of size 1000.
+// a third of them are sized if withBound is true.
+func wandering(r *rand.Rand, id int64, withBound bool) siface.ISpatial {
+	x, y, z := r.Int31n(3000)-1000, r.Int31n(3000)-1000, r.Int31n(3000)-1000
+	if withBound && id%3 == 0 {
+		return sized(id, x, y, z, r.Int31n(60))
+	}
+	return mocks.CreateMockSpatial(id, x, y, z)
+}
+
+func TestOutOfBounds_Conformance(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	cases := []struct {
+		name   string
+		policy consts.OutOfBounds
+		opt    []Option
+	}{
+		{"expand", consts.OutOfBoundsExpand, nil},
+		{"expand loose", consts.OutOfBoundsExpand, []Option{WithLooseFactor(2)}},
+		{"clamp", consts.OutOfBoundsClamp, nil},
+		{"clamp loose", consts.OutOfBoundsClamp, []Option{WithLooseFactor(2)}},
+		{"overflow", consts.OutOfBoundsOverflow, nil},
+		{"overflow loose", consts.OutOfBoundsOverflow, []Option{WithLooseFactor(2)}},
+	}
+	for _, c := range cases {
+		opt := append([]Option{WithOutOfBounds(c.policy)}, c.opt...)
+		octree, err := CreateOctree(bound, 5, 4, opt...)
+		assert.Nil(t, err)
+		quadtree, err := CreateQuadtree(bound, 5, 4, opt...)
+		assert.Nil(t, err)
+		pairs := []struct {
+			search    siface.ISearch
+			reference siface.ISearch
+		}{
+			{octree, CreateRTree(consts.Dim3, 2, 8)},
+			{quadtree, CreateRTree(consts.Dim2, 2, 8)},
+		}
+		r := rand.New(rand.NewSource(int64(c.policy)))
+		for i := int64(0); i < 600; i++ {
+			entity := wandering(r, i, c.opt != nil)
+			for _, p := range pairs {
+				assert.True(t, p.search.Add(entity), c.name)
+				p.reference.Add(entity)
+			}
+		}
+		for i := int64(0); i < 300; i++ {
+			for _, p := range pairs {
+				if i%3 == 0 {
+					assert.True(t, p.search.Remove(i), c.name)
+					p.reference.Remove(i)
+					continue
+				}
+				old, _ := p.search.Get(i)
+				moved := wandering(r, i, c.opt != nil)
+				assert.True(t, p.search.Update(moved, old.GetLocation()), c.name)
+				p.reference.Update(moved, old.GetLocation())
+			}
+		}
+		centers := [][]float32{{500, 500, 500}, {20, 980, 20}, {990, 10, 500}, {-800, 1500, -300}, {1900, -900, 1900}, {1200, 500, 500}}
+		for _, p := range pairs {
+			for _, center := range centers {
+				box := bounds.NewBound(
+					geo.NewVec3Int(int32(center[0])-150, int32(center[1])-150, int32(center[2])-150),
+					geo.NewVec3Int(int32(center[0])+150, int32(center[1])+150, int32(center[2])+150))
+				assert.Equal(t, sortedIds(p.reference.GetSurroundingEntities(center, 200)), sortedIds(p.search.GetSurroundingEntities(center, 200)), c.name)
+				assert.Equal(t, sortedIds(p.reference.GetIntersecting(box)), sortedIds(p.search.GetIntersecting(box)), c.name)
+				assert.Equal(t, sortedIds(p.reference.GetNearest(center, 20, 0)), sortedIds(p.search.GetNearest(center, 20, 0)), c.name)
+				assert.Equal(t, sortedIds(p.reference.GetEntitiesInBound(box)), sortedIds(p.search.GetEntitiesInBound(box)), c.name)
+			}
+			assert.Equal(t, p.reference.Len(), p.search.Len(), c.name)
+			assert.Equal(t, p.reference.Len(), p.search.Stats().Entities, c.name)
+		}
+	}
+}
+
+func TestOutOfBounds_Clamp(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for _, search := range []siface.ISearch{
+		must(CreateOctree(bound, 3, 2, WithOutOfBounds(consts.OutOfBoundsClamp))),
+		must(CreateQuadtree(bound, 3, 2, WithOutOfBounds(consts.OutOfBoundsClamp))),
+	} {
+		// divided before the entity out of the bound comes, which is held by a leaf on the edge.
+		for i := int64(1); i <= 3; i++ {
+			assert.True(t, search.Add(mocks.CreateMockSpatial(i, int32(i)*20, 50, int32(i)*20)))
+		}
+		assert.True(t, search.Add(mocks.CreateMockSpatial(4, 180, 50, 50)))
+		assert.Equal(t, []int64{4}, sortedIds(search.GetSurroundingEntities([]float32{180, 50, 50}, 5)))
+		assert.Equal(t, []int64{4}, sortedIds(search.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(170, 40, 40), geo.NewVec3Int(190, 60, 60)))))
+		assert.Equal(t, []int64{4}, sortedIds(search.GetNearest([]float32{180, 50, 50}, 1, 0)))
+		assert.True(t, search.Update(mocks.CreateMockSpatial(4, 50, 50, -300), geo.NewVec3Int(180, 50, 50)))
+		assert.Empty(t, search.GetSurroundingEntities([]float32{180, 50, 50}, 5))
+		assert.Equal(t, []int64{4}, sortedIds(search.GetSurroundingEntities([]float32{50, 50, -300}, 5)))
+	}
+}
+
+func TestOutOfBounds_Reject(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for _, search := range []siface.ISearch{
+		must(CreateOctree(bound, 3, 2)),
+		must(CreateQuadtree(bound, 3, 2, WithOutOfBounds(consts.OutOfBoundsReject))),
+	} {
+		assert.False(t, search.Add(mocks.CreateMockSpatial(1, 150, 50, 50)))
+		assert.True(t, search.Add(mocks.CreateMockSpatial(2, 50, 50, 50)))
+		assert.False(t, search.Update(mocks.CreateMockSpatial(2, -50, 50, 50), geo.NewVec3Int(50, 50, 50)))
+		entity, _ := search.Get(2)
+		assert.Equal(t, geo.NewVec3Int(50, 50, 50), entity.GetLocation())
+		assert.Equal(t, 1, search.Len())
+	}
+}
+
+func TestOutOfBounds_AutoExpand(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree := must(CreateOctree(bound, 3, 1, WithAutoExpand()))
+	octree.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	octree.Add(mocks.CreateMockSpatial(2, 90, 90, 90))
+	assert.True(t, octree.Add(mocks.CreateMockSpatial(3, -250, 150, 50)))
+	nodes := nodesOf(octree)
+	// doubled twice toward -x and +y, and toward the side of the center the entity is on for z.
+	assert.Equal(t, bounds.NewBound(geo.NewVec3Int(-300, 0, -200), geo.NewVec3Int(100, 400, 200)), nodes[0].Bound)
+	assert.Equal(t, 3, octree.Stats().Depth)
+	// the old root keeps its nodes, two levels deeper.
+	assert.Contains(t, nodes, siface.NodeInfo{Bound: bound, Depth: 2, Count: 0})
+	assert.Equal(t, []int64{3}, sortedIds(octree.GetSurroundingEntities([]float32{-250, 150, 50}, 1)))
+
+	assert.Equal(t, 2, octree.AddBatch([]siface.ISpatial{mocks.CreateMockSpatial(4, 50, 50, 50), mocks.CreateMockSpatial(5, 50, 5000, 50)}))
+	assert.True(t, octree.Update(mocks.CreateMockSpatial(1, -9000, 10, 10), geo.NewVec3Int(10, 10, 10)))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, sortedIds(octree.GetSurroundingEntities([]float32{0, 0, 0}, 10000)))
+	// the bound can't grow beyond int32.
+	assert.False(t, octree.Add(mocks.CreateMockSpatial(6, 1<<31-1, 0, 0)))
+
+	var buf bytes.Buffer
+	assert.Nil(t, octree.Snapshot(&buf))
+	restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+		entity, _ := octree.Get(id)
+		return entity
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, nodesOf(octree), nodesOf(restored))
+	// still expanding.
+	assert.True(t, restored.Add(mocks.CreateMockSpatial(7, 50, 50, -9000)))
+}
+
+func TestOutOfBounds_Snapshot(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	for _, policy := range []consts.OutOfBounds{consts.OutOfBoundsClamp, consts.OutOfBoundsOverflow} {
+		quadtree := must(CreateQuadtree(bound, 5, 4, WithOutOfBounds(policy)))
+		r := rand.New(rand.NewSource(7))
+		for i := int64(0); i < 300; i++ {
+			quadtree.Add(wandering(r, i, false))
+		}
+		var buf bytes.Buffer
+		assert.Nil(t, quadtree.Snapshot(&buf))
+		restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+			entity, _ := quadtree.Get(id)
+			return entity
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nodesOf(quadtree), nodesOf(restored))
+		assert.Equal(t, quadtree.Len(), restored.Len())
+		center := []float32{1000, 500, 1000}
+		assert.Equal(t, sortedIds(quadtree.GetSurroundingEntities(center, 500)), sortedIds(restored.GetSurroundingEntities(center, 500)))
+		assert.True(t, restored.Add(mocks.CreateMockSpatial(1000, -5000, 0, 0)))
+	}
+}
+
+func must(search siface.ISearch, err error) siface.ISearch {
+	if err != nil {
+		panic(err)
+	}
+	return search
+}
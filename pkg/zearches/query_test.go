@@ -0,0 +1,110 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// populate adds n entities scattered over the bound of size 1000 to the searches.
+func populate(searches map[string]siface.ISearch, n int) {
+	for _, search := range searches {
+		for i := 0; i < n; i++ {
+			search.Add(mocks.CreateMockSpatial(int64(i+1), int32(i*7%1000), int32(i*13%1000), int32(i*31%1000)))
+		}
+	}
+}
+
+func TestVisitSurroundingEntities_Stop(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := createAll(t, bound)
+	populate(searches, 2000)
+	center := []float32{500, 500, 500}
+	for name, search := range searches {
+		all := search.GetSurroundingEntities(center, 200)
+		assert.Greater(t, len(all), 3, name)
+		visited := make([]siface.ISpatial, 0)
+		search.VisitSurroundingEntities(center, 200, func(entity siface.ISpatial) bool {
+			visited = append(visited, entity)
+			return len(visited) < 3
+		})
+		assert.Len(t, visited, 3, name)
+		assert.Subset(t, all, visited, name)
+		dst := search.AppendSurroundingEntities(visited[:1], center, 200)
+		assert.Equal(t, visited[0], dst[0], name)
+		assert.ElementsMatch(t, all, dst[1:], name)
+	}
+}
+
+func TestVisitSurroundingEntities_ZeroAllocs(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := createAll(t, bound)
+	populate(searches, 2000)
+	center := []float32{500, 500, 500}
+	count := 0
+	visit := func(entity siface.ISpatial) bool {
+		count++
+		return true
+	}
+	dst := make([]siface.ISpatial, 0, 2000)
+	for name, search := range searches {
+		allocs := testing.AllocsPerRun(100, func() {
+			search.VisitSurroundingEntities(center, 100, visit)
+		})
+		assert.Zero(t, allocs, name)
+		allocs = testing.AllocsPerRun(100, func() {
+			dst = search.AppendSurroundingEntities(dst[:0], center, 100)
+		})
+		assert.Zero(t, allocs, name)
+	}
+}
+
+func benchmarkSurroundings(b *testing.B, query func(search siface.ISearch, center []float32)) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := map[string]siface.ISearch{}
+	searches["octree"], _ = CreateOctree(bound, 5, 16)
+	searches["quadtree"], _ = CreateQuadtree(bound, 5, 16)
+	searches["grid"], _ = CreateGrid(bound, geo.NewVec3Int(50, 50, 50))
+	searches["crosslist"], _ = CreateCrossList(consts.Dim3)
+	searches["rtree"] = CreateRTree(consts.Dim3, 4, 16)
+	populate(searches, 10000)
+	center := []float32{500, 500, 500}
+	for _, name := range []string{"octree", "quadtree", "grid", "crosslist", "rtree"} {
+		search := searches[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				query(search, center)
+			}
+		})
+	}
+}
+
+func BenchmarkGetSurroundingEntities(b *testing.B) {
+	benchmarkSurroundings(b, func(search siface.ISearch, center []float32) {
+		search.GetSurroundingEntities(center, 50)
+	})
+}
+
+func BenchmarkAppendSurroundingEntities(b *testing.B) {
+	dst := make([]siface.ISpatial, 0, 10000)
+	benchmarkSurroundings(b, func(search siface.ISearch, center []float32) {
+		dst = search.AppendSurroundingEntities(dst[:0], center, 50)
+	})
+}
+
+func BenchmarkVisitSurroundingEntities(b *testing.B) {
+	count := 0
+	visit := func(entity siface.ISpatial) bool {
+		count++
+		return true
+	}
+	benchmarkSurroundings(b, func(search siface.ISearch, center []float32) {
+		search.VisitSurroundingEntities(center, 50, visit)
+	})
+}
@@ -0,0 +1,72 @@
+// Package zearches .
+package zearches
+
+import (
+	"fmt"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/crosslist"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/grid"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/octree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/option"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/quadtree"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/rtree"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"io"
+)
+
+// ErrSnapshot is returned by Restore when the snapshot is malformed or of an unsupported version.
+var ErrSnapshot = tree.ErrSnapshot
+
+// Restore restores a search tree from a snapshot written by its Snapshot method, of any kind.
+// The config(bound, maxDepth, capacity, merge policy, etc.) and the structure of the nodes are restored,
+// the entities are resolved by their IDs since only their IDs and locations are persisted.
+// Parameters:
+// - r: the reader of the snapshot.
+// - resolve: returns the entity of an ID, the entity is skipped if it's nil,
+// and added again if it's not at its snapshot location anymore.
+// - opt: variadic optional parameters which are not persisted, like WithScale, WithMetric, WithDrawPath, WithDuplicate and WithConcurrency.
+// WithMergeIf, WithPlane, WithLooseFactor and WithOutOfBounds are ignored, they are restored from the snapshot.
+// Returns an ISpatial search interface and an error if the snapshot is invalid.
+func Restore(r io.Reader, resolve func(id int64) siface.ISpatial, opt ...Option) (siface.ISearch, error) {
+	s := &OptionalSettings{}
+	for _, o := range opt {
+		o(s)
+	}
+	d, kind, err := tree.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	optional := []option.Optional{
+		option.WithScale(s.ScaleFunc),
+		option.WithDrawPath(s.path),
+		option.WithMetric(s.metric),
+		option.WithDuplicate(s.duplicate),
+	}
+	var search siface.ISearch
+	switch kind {
+	case tree.KindOctree:
+		search, err = nilIfErr(octree.Restore(d, resolve, optional...))
+	case tree.KindQuadtree:
+		search, err = nilIfErr(quadtree.Restore(d, resolve, optional...))
+	case tree.KindGrid:
+		search, err = nilIfErr(grid.Restore(d, resolve, optional...))
+	case tree.KindCrossList:
+		search, err = nilIfErr(crosslist.Restore(d, resolve, optional...))
+	case tree.KindRTree:
+		search, err = nilIfErr(rtree.Restore(d, resolve, optional...))
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %d", ErrSnapshot, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.wrap(search), nil
+}
+
+// nilIfErr converts the result of a restore to an ISearch, avoiding a non-nil interface holding a nil pointer.
+func nilIfErr[S siface.ISearch](search S, err error) (siface.ISearch, error) {
+	if err != nil {
+		return nil, err
+	}
+	return search, nil
+}
@@ -0,0 +1,129 @@
+// Package zearches .
+package zearches
+
+import (
+	"bytes"
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"slices"
+	"testing"
+)
+
+func sortedIds(entities []siface.ISpatial) []int64 {
+	ret := make([]int64, 0, len(entities))
+	for _, entity := range entities {
+		ret = append(ret, entity.GetID())
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := createAll(t, bound)
+	populate(searches, 500)
+	center := []float32{500, 500, 500}
+	for name, search := range searches {
+		var buf bytes.Buffer
+		assert.Nil(t, search.Snapshot(&buf), name)
+		restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+			entity, _ := search.Get(id)
+			return entity
+		})
+		assert.Nil(t, err, name)
+		assert.Equal(t, search.Len(), restored.Len(), name)
+		assert.Equal(t, search.Stats().Entities, restored.Stats().Entities, name)
+		assert.Equal(t, sortedIds(search.GetSurroundingEntities(center, 200)), sortedIds(restored.GetSurroundingEntities(center, 200)), name)
+		assert.Equal(t, sortedIds(search.GetNearest(center, 10, 0)), sortedIds(restored.GetNearest(center, 10, 0)), name)
+	}
+	// the nodes are restored as they were.
+	for _, name := range []string{"octree", "quadtree"} {
+		var buf bytes.Buffer
+		assert.Nil(t, searches[name].Snapshot(&buf), name)
+		restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+			entity, _ := searches[name].Get(id)
+			return entity
+		})
+		assert.Nil(t, err, name)
+		var expected, actual []siface.NodeInfo
+		for info := range searches[name].(siface.INodes).Nodes() {
+			expected = append(expected, info)
+		}
+		for info := range restored.(siface.INodes).Nodes() {
+			actual = append(actual, info)
+		}
+		assert.Equal(t, expected, actual, name)
+	}
+}
+
+func TestSnapshot_Config(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	quadtree, err := CreateQuadtree(bound, 4, 1, WithMergeIf(true), WithPlane(consts.PlaneXY))
+	assert.Nil(t, err)
+	quadtree.Add(mocks.CreateMockSpatial(1, 10, 10, 90))
+	quadtree.Add(mocks.CreateMockSpatial(2, 90, 90, 10))
+	var buf bytes.Buffer
+	assert.Nil(t, quadtree.Snapshot(&buf))
+	restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+		entity, _ := quadtree.Get(id)
+		return entity
+	}, WithConcurrency())
+	assert.Nil(t, err)
+	_, ok := restored.(*concurrentNodes)
+	assert.True(t, ok)
+	// measured on x/y, z is ignored.
+	assert.Equal(t, []int64{1}, sortedIds(restored.GetSurroundingEntities([]float32{10, 10, 0}, 5)))
+	// the empty siblings are merged.
+	restored.Remove(1)
+	restored.Remove(2)
+	assert.Equal(t, 1, restored.Stats().Nodes)
+}
+
+func TestSnapshot_Resolve(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	for name, search := range createAll(t, bound) {
+		for i := int64(1); i <= 10; i++ {
+			search.Add(mocks.CreateMockSpatial(i, int32(i*9), int32(i*9), int32(i*9)))
+		}
+		var buf bytes.Buffer
+		assert.Nil(t, search.Snapshot(&buf), name)
+		restored, err := Restore(&buf, func(id int64) siface.ISpatial {
+			switch id {
+			case 1:
+				// gone since the snapshot.
+				return nil
+			case 2:
+				// moved since the snapshot.
+				return mocks.CreateMockSpatial(2, 95, 95, 95)
+			}
+			entity, _ := search.Get(id)
+			return entity
+		})
+		assert.Nil(t, err, name)
+		assert.Equal(t, 9, restored.Len(), name)
+		assert.False(t, restored.Contains(1), name)
+		assert.Equal(t, []int64{2, 10}, sortedIds(restored.GetSurroundingEntities([]float32{95, 95, 95}, 10)), name)
+	}
+}
+
+func TestSnapshot_Invalid(t *testing.T) {
+	resolve := func(id int64) siface.ISpatial { return nil }
+	_, err := Restore(bytes.NewReader([]byte("nope")), resolve)
+	assert.NotNil(t, err)
+	_, err = Restore(bytes.NewReader([]byte("NOPE\x01\x00\x01")), resolve)
+	assert.ErrorIs(t, err, ErrSnapshot)
+	_, err = Restore(bytes.NewReader([]byte("ZSNP\x09\x00\x01")), resolve)
+	assert.ErrorIs(t, err, ErrSnapshot)
+	// truncated.
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, _ := CreateOctree(bound, 3, 2)
+	octree.Add(mocks.CreateMockSpatial(1, 10, 10, 10))
+	var buf bytes.Buffer
+	assert.Nil(t, octree.Snapshot(&buf))
+	_, err = Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), resolve)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,71 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLenAndStats(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(1000, 1000, 1000))
+	searches := createAll(t, bound)
+	for name, search := range searches {
+		assert.Zero(t, search.Len(), name)
+		assert.Zero(t, search.Stats().Entities, name)
+	}
+	populate(searches, 500)
+	for name, search := range searches {
+		search.Remove(1)
+		assert.Equal(t, 499, search.Len(), name)
+		stats := search.Stats()
+		assert.Equal(t, 499, stats.Entities, name)
+		assert.Positive(t, stats.MemoryBytes, name)
+	}
+	for _, name := range []string{"octree", "quadtree", "grid"} {
+		stats := searches[name].Stats()
+		assert.Positive(t, stats.Leaves, name)
+		assert.GreaterOrEqual(t, stats.Nodes, stats.Leaves, name)
+		assert.Len(t, stats.DepthHistogram, stats.Depth+1, name)
+		sum := 0
+		for _, n := range stats.DepthHistogram {
+			sum += n
+		}
+		assert.Equal(t, stats.Leaves, sum, name)
+		assert.InDelta(t, 499/float64(stats.Leaves), stats.AvgLeafEntities, 1e-9, name)
+		assert.GreaterOrEqual(t, float64(stats.MaxLeafEntities), stats.AvgLeafEntities, name)
+	}
+	assert.Positive(t, searches["rtree"].Stats().Nodes)
+}
+
+func TestStats_Overflow(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, _ := CreateOctree(bound, 2, 2)
+	// all at the same location, the leaf at the maximum depth holds them all
+	for i := int64(1); i <= 5; i++ {
+		octree.Add(mocks.CreateMockSpatial(i, 10, 10, 10))
+	}
+	octree.Add(mocks.CreateMockSpatial(6, 90, 90, 90))
+	stats := octree.Stats()
+	assert.Equal(t, 9, stats.Nodes)
+	assert.Equal(t, 8, stats.Leaves)
+	assert.Equal(t, 1, stats.Depth)
+	assert.Equal(t, []int{0, 8}, stats.DepthHistogram)
+	assert.Equal(t, 5, stats.MaxLeafEntities)
+	assert.Equal(t, 5, stats.Overflow)
+}
+
+func TestStats_LooseLeaves(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, _ := CreateOctree(bound, 3, 1, WithLooseFactor(1))
+	for i := int64(1); i <= 8; i++ {
+		octree.Add(mocks.CreateMockSpatial(i, int32(i*10), int32(i*10), int32(i*10)))
+	}
+	// straddles the children of the root, it stays in the root.
+	octree.Add(sized(9, 50, 50, 50, 80))
+	stats := octree.Stats()
+	assert.Equal(t, 9, stats.Entities)
+	assert.InDelta(t, 8/float64(stats.Leaves), stats.AvgLeafEntities, 1e-9)
+}
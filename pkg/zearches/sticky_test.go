@@ -0,0 +1,64 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSticky_Divide(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	wall := sized(1, 50, 50, 50, 20)
+	for _, create := range []func() (siface.ISearch, error){
+		func() (siface.ISearch, error) { return CreateOctree(bound, 3, 2, WithSticky()) },
+		func() (siface.ISearch, error) { return CreateQuadtree(bound, 3, 2, WithSticky()) },
+	} {
+		search, err := create()
+		assert.Nil(t, err)
+		// the wall is in the root when it's divided.
+		search.Add(wall)
+		search.Add(mocks.CreateMockSpatial(2, 10, 10, 10))
+		search.Add(mocks.CreateMockSpatial(3, 90, 90, 90))
+		nodes := nodesOf(search)
+		assert.Greater(t, len(nodes), 1)
+		assert.Equal(t, 1, nodes[0].Count)
+		// added to the divided root.
+		door := sized(4, 50, 20, 20, 4)
+		search.Add(door)
+		assert.Equal(t, 2, nodesOf(search)[0].Count)
+		// found by their bounds.
+		box := bounds.NewBound(geo.NewVec3Int(55, 0, 55), geo.NewVec3Int(60, 100, 60))
+		assert.Equal(t, []siface.ISpatial{wall}, search.GetEntitiesInBound(box))
+		assert.Equal(t, []int64{1, 4}, sortedIds(search.GetIntersecting(bounds.NewBound(geo.NewVec3Int(51, 0, 21), geo.NewVec3Int(58, 100, 58)))))
+		// the ones fitting in a child move down.
+		small := sized(4, 20, 20, 20, 4)
+		assert.True(t, search.Update(small, door.GetLocation()))
+		assert.Equal(t, 1, nodesOf(search)[0].Count)
+		assert.True(t, search.Remove(1))
+		assert.Equal(t, 0, nodesOf(search)[0].Count)
+		assert.Equal(t, 3, search.Stats().Entities)
+	}
+}
+
+func TestSticky_Merge(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, err := CreateOctree(bound, 3, 3, WithSticky(), WithMergeIf(true))
+	assert.Nil(t, err)
+	wall := sized(1, 50, 50, 50, 20)
+	octree.Add(wall)
+	for i := int64(2); i <= 4; i++ {
+		octree.Add(mocks.CreateMockSpatial(i, int32(i*10), 10, 10))
+	}
+	assert.Greater(t, octree.Stats().Nodes, 1)
+	// the wall in the root counts, the children are merged when it and their entities fit in the root again.
+	octree.Remove(4)
+	assert.Greater(t, octree.Stats().Nodes, 1)
+	octree.Remove(3)
+	assert.Equal(t, 1, octree.Stats().Nodes)
+	assert.Equal(t, []int64{1, 2}, sortedIds(octree.GetEntitiesInBound(bound)))
+	assert.Equal(t, []siface.ISpatial{wall}, octree.GetEntitiesInBound(bounds.NewBound(geo.NewVec3Int(58, 58, 58), geo.NewVec3Int(70, 70, 70))))
+}
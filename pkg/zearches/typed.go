@@ -0,0 +1,219 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/cozmo-zh/zearches/util"
+	"io"
+	"iter"
+)
+
+// typed adapts an ISearch to siface.ISearchOf[T].
+type typed[T siface.ISpatial] struct {
+	origin siface.ISearch
+}
+
+// NewTyped wraps a search tree holding entities of type T, so that its queries take and return T.
+//
+// The entities of other types added to the search tree through Untyped are skipped by the queries.
+func NewTyped[T siface.ISpatial](search siface.ISearch) siface.ISearchOf[T] {
+	return &typed[T]{origin: search}
+}
+
+// CreateOctreeOf creates a new Octree of the entities of type T, see CreateOctree.
+func CreateOctreeOf[T siface.ISpatial](bound bounds.Bound, maxDepth, capacity int, opt ...Option) (siface.ISearchOf[T], error) {
+	return typedOf[T](CreateOctree(bound, maxDepth, capacity, opt...))
+}
+
+// CreateQuadtreeOf creates a new Quadtree of the entities of type T, see CreateQuadtree.
+func CreateQuadtreeOf[T siface.ISpatial](bound bounds.Bound, maxDepth, capacity int, opt ...Option) (siface.ISearchOf[T], error) {
+	return typedOf[T](CreateQuadtree(bound, maxDepth, capacity, opt...))
+}
+
+// CreateGridOf creates a new uniform Grid of the entities of type T, see CreateGrid.
+func CreateGridOf[T siface.ISpatial](bound bounds.Bound, cellSize geo.Vec3Int, opt ...Option) (siface.ISearchOf[T], error) {
+	return typedOf[T](CreateGrid(bound, cellSize, opt...))
+}
+
+// CreateCrossListOf creates a new cross-linked list of the entities of type T, see CreateCrossList.
+func CreateCrossListOf[T siface.ISpatial](dim consts.Dim, opt ...Option) (siface.ISearchOf[T], error) {
+	return typedOf[T](CreateCrossList(dim, opt...))
+}
+
+// CreateRTreeOf creates a new RTree of the entities of type T, see CreateRTree.
+func CreateRTreeOf[T siface.ISpatial](dim consts.Dim, min, max int, opt ...Option) siface.ISearchOf[T] {
+	return NewTyped[T](CreateRTree(dim, min, max, opt...))
+}
+
+// typedOf wraps the search tree created, if there is no error.
+func typedOf[T siface.ISpatial](search siface.ISearch, err error) (siface.ISearchOf[T], error) {
+	if err != nil {
+		return nil, err
+	}
+	return NewTyped[T](search), nil
+}
+
+func (t *typed[T]) Add(entity T) bool {
+	return t.origin.Add(entity)
+}
+
+func (t *typed[T]) AddE(entity T) error {
+	return t.origin.AddE(entity)
+}
+
+func (t *typed[T]) AddBatch(entities []T) int {
+	batch := make([]siface.ISpatial, len(entities))
+	for i, entity := range entities {
+		batch[i] = entity
+	}
+	return t.origin.AddBatch(batch)
+}
+
+func (t *typed[T]) Remove(entityId int64) bool {
+	return t.origin.Remove(entityId)
+}
+
+func (t *typed[T]) RemoveE(entityId int64) error {
+	return t.origin.RemoveE(entityId)
+}
+
+func (t *typed[T]) Get(entityId int64) (T, bool) {
+	if entity, ok := t.origin.Get(entityId); ok {
+		e, ok := entity.(T)
+		return e, ok
+	}
+	var zero T
+	return zero, false
+}
+
+func (t *typed[T]) Contains(entityId int64) bool {
+	return t.origin.Contains(entityId)
+}
+
+func (t *typed[T]) Update(entity T, oldLocation geo.Vec3Int) bool {
+	return t.origin.Update(entity, oldLocation)
+}
+
+func (t *typed[T]) UpdateE(entity T, oldLocation geo.Vec3Int) error {
+	return t.origin.UpdateE(entity, oldLocation)
+}
+
+func (t *typed[T]) GetSurroundingEntities(center []float32, radius float32, filters ...func(entity T) bool) []T {
+	return cast[T](t.origin.GetSurroundingEntities(center, radius, untyped(filters)...))
+}
+
+func (t *typed[T]) AppendSurroundingEntities(dst []T, center []float32, radius float32, filters ...func(entity T) bool) []T {
+	t.VisitSurroundingEntities(center, radius, func(entity T) bool {
+		dst = append(dst, entity)
+		return true
+	}, filters...)
+	return dst
+}
+
+func (t *typed[T]) VisitSurroundingEntities(center []float32, radius float32, visit func(entity T) bool, filters ...func(entity T) bool) {
+	t.origin.VisitSurroundingEntities(center, radius, func(entity siface.ISpatial) bool {
+		e, ok := entity.(T)
+		if !ok {
+			return true
+		}
+		for _, f := range filters {
+			if !f(e) {
+				return true
+			}
+		}
+		return visit(e)
+	})
+}
+
+func (t *typed[T]) GetSurroundingEntitiesWithMetric(center []float32, radius float32, metric util.DistanceMetric, filters ...func(entity T) bool) []T {
+	return cast[T](t.origin.GetSurroundingEntitiesWithMetric(center, radius, metric, untyped(filters)...))
+}
+
+func (t *typed[T]) GetNearest(center []float32, k int, maxDistance float32, filters ...func(entity T) bool) []T {
+	return cast[T](t.origin.GetNearest(center, k, maxDistance, untyped(filters)...))
+}
+
+func (t *typed[T]) GetEntitiesInBound(bound bounds.Bound, filters ...func(entity T) bool) []T {
+	return cast[T](t.origin.GetEntitiesInBound(bound, untyped(filters)...))
+}
+
+func (t *typed[T]) GetIntersecting(bound bounds.Bound, filters ...func(entity T) bool) []T {
+	return cast[T](t.origin.GetIntersecting(bound, untyped(filters)...))
+}
+
+func (t *typed[T]) GetCollisionPairs() [][2]T {
+	pairs := t.origin.GetCollisionPairs()
+	ret := make([][2]T, 0, len(pairs))
+	for _, pair := range pairs {
+		e1, ok1 := pair[0].(T)
+		e2, ok2 := pair[1].(T)
+		if ok1 && ok2 {
+			ret = append(ret, [2]T{e1, e2})
+		}
+	}
+	return ret
+}
+
+func (t *typed[T]) All() iter.Seq[T] {
+	return func(yield func(entity T) bool) {
+		for entity := range t.origin.All() {
+			if e, ok := entity.(T); ok && !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+func (t *typed[T]) Within(center []float32, radius float32, filters ...func(entity T) bool) iter.Seq[T] {
+	return func(yield func(entity T) bool) {
+		t.VisitSurroundingEntities(center, radius, yield, filters...)
+	}
+}
+
+func (t *typed[T]) Len() int {
+	return t.origin.Len()
+}
+
+func (t *typed[T]) Stats() siface.Stats {
+	return t.origin.Stats()
+}
+
+func (t *typed[T]) Snapshot(w io.Writer) error {
+	return t.origin.Snapshot(w)
+}
+
+func (t *typed[T]) ToDot() error {
+	return t.origin.ToDot()
+}
+
+func (t *typed[T]) Untyped() siface.ISearch {
+	return t.origin
+}
+
+// untyped converts the typed filters to the filters of ISearch,
+// the first one rejects the entities not of type T, so they are not counted in k by GetNearest either.
+func untyped[T siface.ISpatial](filters []func(entity T) bool) []func(entity siface.ISpatial) bool {
+	ret := make([]func(entity siface.ISpatial) bool, 0, len(filters)+1)
+	ret = append(ret, func(entity siface.ISpatial) bool {
+		_, ok := entity.(T)
+		return ok
+	})
+	for _, f := range filters {
+		ret = append(ret, func(entity siface.ISpatial) bool {
+			return f(entity.(T))
+		})
+	}
+	return ret
+}
+
+// cast converts the entities to T, they are all of type T as rejected by untyped otherwise.
+func cast[T siface.ISpatial](entities []siface.ISpatial) []T {
+	ret := make([]T, len(entities))
+	for i, entity := range entities {
+		ret[i] = entity.(T)
+	}
+	return ret
+}
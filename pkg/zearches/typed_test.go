@@ -0,0 +1,59 @@
+// Package zearches .
+package zearches
+
+import (
+	"github.com/cozmo-zh/zearches/consts"
+	"github.com/cozmo-zh/zearches/internal/pkg/tree/mocks"
+	"github.com/cozmo-zh/zearches/pkg/bounds"
+	"github.com/cozmo-zh/zearches/pkg/geo"
+	"github.com/cozmo-zh/zearches/pkg/siface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type player struct {
+	mocks.MockSpatial
+	name string
+}
+
+func newPlayer(id int64, x, y, z int32, name string) *player {
+	return &player{MockSpatial: *mocks.CreateMockSpatial(id, x, y, z).(*mocks.MockSpatial), name: name}
+}
+
+func TestTyped_Queries(t *testing.T) {
+	bound := bounds.NewBound(geo.NewVec3Int(0, 0, 0), geo.NewVec3Int(100, 100, 100))
+	octree, err := CreateOctreeOf[*player](bound, 3, 2)
+	assert.Nil(t, err)
+	quadtree, err := CreateQuadtreeOf[*player](bound, 3, 2)
+	assert.Nil(t, err)
+	grid, err := CreateGridOf[*player](bound, geo.NewVec3Int(10, 10, 10))
+	assert.Nil(t, err)
+	crosslist, err := CreateCrossListOf[*player](consts.Dim3)
+	assert.Nil(t, err)
+	searches := map[string]siface.ISearchOf[*player]{
+		"octree":    octree,
+		"quadtree":  quadtree,
+		"rtree":     CreateRTreeOf[*player](consts.Dim3, 2, 8),
+		"grid":      grid,
+		"crosslist": crosslist,
+	}
+	for name, search := range searches {
+		alice := newPlayer(1, 10, 10, 10, "alice")
+		bob := newPlayer(2, 12, 10, 10, "bob")
+		assert.True(t, search.Add(alice), name)
+		assert.True(t, search.Add(bob), name)
+		// an entity of another type is skipped by the queries
+		search.Untyped().Add(mocks.CreateMockSpatial(3, 11, 10, 10))
+
+		got, ok := search.Get(1)
+		assert.True(t, ok, name)
+		assert.Equal(t, "alice", got.name, name)
+		_, ok = search.Get(3)
+		assert.False(t, ok, name)
+
+		ret := search.GetSurroundingEntities([]float32{10, 10, 10}, 5, func(p *player) bool { return p.name != "alice" })
+		assert.Equal(t, []*player{bob}, ret, name)
+		assert.Equal(t, []*player{alice, bob}, search.GetNearest([]float32{10, 10, 10}, 2, 0), name)
+		assert.ElementsMatch(t, []*player{alice, bob}, search.GetEntitiesInBound(bound), name)
+	}
+}
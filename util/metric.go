@@ -0,0 +1,92 @@
+// Package util provides utility functions for distance calculations.
+package util
+
+import "math"
+
+// DistanceMetric measures the distance between two points, it's used by the surrounding queries.
+//
+// The distance must not decrease when a point moves away from the other on any axis,
+// so that the queries can prune the space by the reach of the metric.
+type DistanceMetric interface {
+	// Within checks if the distance between p1 and p2 is within distance.
+	Within(p1, p2 []float32, distance float32) bool
+	// Reach returns how far the points within distance of a point reach on each axis(x, y, z),
+	// math.MaxFloat32 if the axis is not limited.
+	Reach(distance float32) [3]float32
+}
+
+// Euclidean2D measures the straight-line distance on the x and z axes, ignoring y.
+type Euclidean2D struct{}
+
+// Within checks if the 2D distance between p1 and p2 is within distance.
+func (Euclidean2D) Within(p1, p2 []float32, distance float32) bool {
+	return WithinDistance2D(p1, p2, distance)
+}
+
+// Reach returns the distance on x and z, y is not limited.
+func (Euclidean2D) Reach(distance float32) [3]float32 {
+	return [3]float32{distance, math.MaxFloat32, distance}
+}
+
+// Euclidean3D measures the straight-line distance, it's a sphere.
+type Euclidean3D struct{}
+
+// Within checks if the 3D distance between p1 and p2 is within distance.
+func (Euclidean3D) Within(p1, p2 []float32, distance float32) bool {
+	return WithinDistance3D(p1, p2, distance)
+}
+
+// Reach returns the distance on all the axes.
+func (Euclidean3D) Reach(distance float32) [3]float32 {
+	return [3]float32{distance, distance, distance}
+}
+
+// Manhattan measures the sum of the distances on each axis, like the moves of grid tactics.
+type Manhattan struct{}
+
+// Within checks if the manhattan distance between p1 and p2 is within distance.
+func (Manhattan) Within(p1, p2 []float32, distance float32) bool {
+	sum := float32(0)
+	for i := 0; i < 3; i++ {
+		sum += float32(math.Abs(float64(p1[i] - p2[i])))
+	}
+	return sum <= distance
+}
+
+// Reach returns the distance on all the axes.
+func (Manhattan) Reach(distance float32) [3]float32 {
+	return [3]float32{distance, distance, distance}
+}
+
+// Chebyshev measures the greatest of the distances on each axis, it's a square(cube) vision for tile games.
+type Chebyshev struct{}
+
+// Within checks if the chebyshev distance between p1 and p2 is within distance.
+func (Chebyshev) Within(p1, p2 []float32, distance float32) bool {
+	for i := 0; i < 3; i++ {
+		if math.Abs(float64(p1[i]-p2[i])) > float64(distance) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reach returns the distance on all the axes.
+func (Chebyshev) Reach(distance float32) [3]float32 {
+	return [3]float32{distance, distance, distance}
+}
+
+// Cylinder measures the 2D distance on the x and z axes within a band of y, like the vision of flying units.
+type Cylinder struct {
+	HalfHeight float32 // The maximum distance on y, whatever the radius is.
+}
+
+// Within checks if the 2D distance between p1 and p2 is within distance, and they are within the band of y.
+func (c Cylinder) Within(p1, p2 []float32, distance float32) bool {
+	return math.Abs(float64(p1[1]-p2[1])) <= float64(c.HalfHeight) && WithinDistance2D(p1, p2, distance)
+}
+
+// Reach returns the distance on x and z, the half height on y.
+func (c Cylinder) Reach(distance float32) [3]float32 {
+	return [3]float32{distance, c.HalfHeight, distance}
+}